@@ -0,0 +1,440 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"exporter-to-obsidian/internal/client"
+	"exporter-to-obsidian/internal/state"
+	"exporter-to-obsidian/internal/utils"
+)
+
+// 进程退出码
+const (
+	exitOK      = 0 // 执行成功
+	exitFailure = 1 // 导出或检查失败
+	exitUsage   = 2 // 命令行参数错误
+)
+
+// 支持的数据源
+const (
+	sourceDida365 = "dida365"
+	sourceMemos   = "memos"
+)
+
+// exportOptions 各子命令共享的导出选项
+type exportOptions struct {
+	outputDir string
+	sources   map[string]bool
+	fullSync  bool
+}
+
+// command 子命令定义
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) int
+}
+
+// commands 返回所有子命令
+func commands() []command {
+	return []command{
+		{name: "export", summary: "执行一次导出后退出", run: runExportCommand},
+		{name: "daemon", summary: "按固定间隔循环导出（默认命令）", run: runDaemonCommand},
+		{name: "backfill", summary: "补导指定日期范围内的已完成任务、日历摘要与Memos", run: runBackfillCommand},
+		{name: "regenerate", summary: "根据本地历史数据重新生成指定日期范围内的每日摘要", run: runRegenerateCommand},
+		{name: "status", summary: "查看当前配置与导出目录状态", run: runStatusCommand},
+		{name: "doctor", summary: "检查配置、输出目录与各数据源连通性", run: runDoctorCommand},
+	}
+}
+
+// run 解析子命令并执行，返回进程退出码
+func run(args []string) int {
+	if len(args) > 0 && (args[0] == "help" || args[0] == "-h" || args[0] == "--help") {
+		printUsage(os.Stdout)
+		return exitOK
+	}
+
+	// 未指定子命令时保持原有的定时导出行为
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return runDaemonCommand(args)
+	}
+
+	name := args[0]
+
+	for _, cmd := range commands() {
+		if cmd.name == name {
+			return cmd.run(args[1:])
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "未知命令: %s\n\n", name)
+	printUsage(os.Stderr)
+	return exitUsage
+}
+
+// printUsage 输出命令帮助
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "用法: main <命令> [参数]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "命令:")
+	for _, cmd := range commands() {
+		fmt.Fprintf(w, "  %-10s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "使用 \"main <命令> -h\" 查看各命令的参数")
+}
+
+// newFlagSet 创建子命令参数集，并注册共享的导出选项
+func newFlagSet(name string, opts *exportOptions, sources *string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.StringVar(&opts.outputDir, "output", utils.GetEnvOrDefault("OUTPUT_DIR", "."), "输出目录（默认读取 OUTPUT_DIR）")
+	fs.StringVar(sources, "sources", strings.Join([]string{sourceDida365, sourceMemos}, ","), "要导出的数据源，逗号分隔（dida365, memos）")
+	return fs
+}
+
+// parseSources 解析数据源列表
+func parseSources(value string) (map[string]bool, error) {
+	sources := make(map[string]bool)
+	for _, name := range strings.Split(value, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if name != sourceDida365 && name != sourceMemos {
+			return nil, fmt.Errorf("未知数据源: %s", name)
+		}
+		sources[name] = true
+	}
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("至少需要指定一个数据源")
+	}
+	return sources, nil
+}
+
+// parseFlags 解析参数并补全共享选项，失败时返回对应退出码
+func parseFlags(fs *flag.FlagSet, args []string, opts *exportOptions, sources *string) (int, bool) {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK, false
+		}
+		return exitUsage, false
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "多余的参数: %s\n", strings.Join(fs.Args(), " "))
+		return exitUsage, false
+	}
+
+	parsed, err := parseSources(*sources)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage, false
+	}
+	opts.sources = parsed
+
+	// 导出器与状态目录均从环境变量读取输出目录，与命令行参数保持一致
+	os.Setenv("OUTPUT_DIR", opts.outputDir)
+	return exitOK, true
+}
+
+// runExportCommand 执行一次导出
+func runExportCommand(args []string) int {
+	var opts exportOptions
+	var sources string
+	fs := newFlagSet("export", &opts, &sources)
+	fs.BoolVar(&opts.fullSync, "full", false, "忽略本地检查点，执行滴答清单全量同步")
+	if code, ok := parseFlags(fs, args, &opts, &sources); !ok {
+		return code
+	}
+
+	if err := runExport(opts); err != nil {
+		log.Printf("导出失败: %v", err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+// runDaemonCommand 按间隔循环导出，收到中断信号后退出
+func runDaemonCommand(args []string) int {
+	var opts exportOptions
+	var sources string
+	fs := newFlagSet("daemon", &opts, &sources)
+	interval := fs.Duration("interval", 5*time.Minute, "两次导出之间的间隔")
+	if code, ok := parseFlags(fs, args, &opts, &sources); !ok {
+		return code
+	}
+	if *interval <= 0 {
+		fmt.Fprintln(os.Stderr, "interval 必须大于 0")
+		return exitUsage
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// 创建定时器
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	// 立即执行第一次导出
+	if err := runExport(opts); err != nil {
+		log.Printf("导出失败: %v", err)
+		if errors.Is(err, client.ErrAuthFailed) {
+			log.Printf("账号或密码错误，停止定时导出，避免反复登录导致账号被锁定")
+			return exitFailure
+		}
+	}
+
+	// 等待定时器触发或退出信号
+	for {
+		select {
+		case <-ctx.Done():
+			log.Printf("收到退出信号，停止定时导出")
+			return exitOK
+		case <-ticker.C:
+			if err := runExport(opts); err != nil {
+				log.Printf("导出失败: %v", err)
+				if errors.Is(err, client.ErrAuthFailed) {
+					log.Printf("账号或密码错误，停止定时导出，避免反复登录导致账号被锁定")
+					return exitFailure
+				}
+			}
+		}
+	}
+}
+
+// runBackfillCommand 补导指定日期范围的数据
+func runBackfillCommand(args []string) int {
+	var opts exportOptions
+	var sources string
+	fs := newFlagSet("backfill", &opts, &sources)
+	fromStr := fs.String("from", "", "开始日期（YYYY-MM-DD，必填）")
+	toStr := fs.String("to", utils.Now().Format("2006-01-02"), "结束日期（YYYY-MM-DD，默认今天）")
+	if code, ok := parseFlags(fs, args, &opts, &sources); !ok {
+		return code
+	}
+
+	from, to, err := parseDateRange(*fromStr, *toStr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+
+	code := exitOK
+	if opts.sources[sourceDida365] {
+		if err := backfillDida365(opts, from, to); err != nil {
+			log.Printf("补导滴答清单数据失败: %v", err)
+			code = exitFailure
+		}
+	}
+	if opts.sources[sourceMemos] {
+		if err := backfillMemos(opts, from, to); err != nil {
+			log.Printf("补导Memos数据失败: %v", err)
+			code = exitFailure
+		}
+	}
+	return code
+}
+
+// runRegenerateCommand 根据本地历史数据重新生成每日摘要
+func runRegenerateCommand(args []string) int {
+	var opts exportOptions
+	var sources string
+	fs := newFlagSet("regenerate", &opts, &sources)
+	fromStr := fs.String("from", "", "开始日期（YYYY-MM-DD，必填）")
+	toStr := fs.String("to", utils.Now().Format("2006-01-02"), "结束日期（YYYY-MM-DD，默认今天）")
+	if code, ok := parseFlags(fs, args, &opts, &sources); !ok {
+		return code
+	}
+
+	from, to, err := parseDateRange(*fromStr, *toStr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+
+	if !opts.sources[sourceDida365] {
+		log.Printf("regenerate 目前只支持滴答清单")
+		return exitOK
+	}
+	if err := regenerateDida365(opts, from, to); err != nil {
+		log.Printf("重新生成每日摘要失败: %v", err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+// parseDateRange 解析日期范围参数
+func parseDateRange(fromStr, toStr string) (time.Time, time.Time, error) {
+	if fromStr == "" {
+		return time.Time{}, time.Time{}, fmt.Errorf("必须通过 --from 指定开始日期")
+	}
+	from, err := time.ParseInLocation("2006-01-02", fromStr, utils.Location())
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("开始日期格式错误: %v", err)
+	}
+	to, err := time.ParseInLocation("2006-01-02", toStr, utils.Location())
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("结束日期格式错误: %v", err)
+	}
+	if to.Before(from) {
+		return time.Time{}, time.Time{}, fmt.Errorf("结束日期不能早于开始日期")
+	}
+	return from, to, nil
+}
+
+// runStatusCommand 输出当前配置与导出目录的概况
+func runStatusCommand(args []string) int {
+	var opts exportOptions
+	var sources string
+	fs := newFlagSet("status", &opts, &sources)
+	if code, ok := parseFlags(fs, args, &opts, &sources); !ok {
+		return code
+	}
+
+	fmt.Printf("输出目录: %s\n", opts.outputDir)
+
+	if opts.sources[sourceDida365] {
+		fmt.Println("滴答清单:")
+		username, _ := utils.GetSecret("DIDA365_USERNAME")
+		fmt.Printf("  账号: %s\n", valueOrNone(username))
+		lastLogin := ""
+		if session, ok := state.Open().Session(sourceDida365); ok && !session.LoginTime.IsZero() {
+			lastLogin = session.LoginTime.In(utils.Location()).Format("2006-01-02 15:04:05")
+		}
+		fmt.Printf("  上次登录: %s\n", valueOrNone(lastLogin))
+		dirs := []string{
+			utils.GetEnvOrDefault("TASKS_DIR", "Tasks"),
+			utils.GetEnvOrDefault("NOTES_DIR", "Notes"),
+			utils.GetEnvOrDefault("COLUMNS_DIR", "Columns"),
+		}
+		for _, dir := range dirs {
+			fmt.Printf("  %s: %d 个文件\n", dir, countMarkdownFiles(filepath.Join(opts.outputDir, dir)))
+		}
+		dailyDir := filepath.Join(opts.outputDir, utils.GetEnvOrDefault("CALENDAR_DIR", "Calendar"), "1.Daily")
+		fmt.Printf("  最新每日摘要: %s\n", valueOrNone(latestFile(dailyDir)))
+	}
+
+	if opts.sources[sourceMemos] {
+		fmt.Println("Memos:")
+		fmt.Printf("  API: %s\n", valueOrNone(os.Getenv("MEMOS_API")))
+		memosDir := filepath.Join(opts.outputDir, utils.GetEnvOrDefault("MEMOS_DIR", "Memos"))
+		fmt.Printf("  最新每日摘要: %s\n", valueOrNone(latestFile(memosDir)))
+	}
+
+	return exitOK
+}
+
+// runDoctorCommand 检查运行环境，任一检查失败时返回失败退出码
+func runDoctorCommand(args []string) int {
+	var opts exportOptions
+	var sources string
+	fs := newFlagSet("doctor", &opts, &sources)
+	if code, ok := parseFlags(fs, args, &opts, &sources); !ok {
+		return code
+	}
+
+	failed := false
+	check := func(name string, err error) {
+		if err != nil {
+			failed = true
+			fmt.Printf("✘ %s: %v\n", name, err)
+			return
+		}
+		fmt.Printf("✔ %s\n", name)
+	}
+
+	check("输出目录可写", checkWritable(opts.outputDir))
+	check(".env 权限", checkEnvFile(".env"))
+
+	if opts.sources[sourceDida365] {
+		_, err := client.NewDida365Client("", "")
+		check("滴答清单登录", err)
+	}
+
+	if opts.sources[sourceMemos] {
+		memosAPI := os.Getenv("MEMOS_API")
+		memosToken, err := utils.GetSecret("MEMOS_TOKEN")
+		if err != nil {
+			check("Memos 令牌", err)
+		} else if memosAPI == "" || memosToken == "" {
+			fmt.Println("- Memos: 未配置，跳过")
+		} else {
+			name := "Memos 连接"
+			memosClient, err := client.NewMemosClient(memosAPI, memosToken)
+			if err == nil {
+				var version string
+				version, err = memosClient.Ping()
+				name = fmt.Sprintf("Memos 连接（接口版本 %s）", version)
+			}
+			check(name, err)
+		}
+	}
+
+	if failed {
+		return exitFailure
+	}
+	return exitOK
+}
+
+// checkWritable 检查目录是否存在且可写
+func checkWritable(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(dir, ".doctor-*")
+	if err != nil {
+		return err
+	}
+	file.Close()
+	return os.Remove(file.Name())
+}
+
+// checkEnvFile 检查保存账号密码的 .env 文件不能被其他用户读取，文件不存在时跳过
+func checkEnvFile(path string) error {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if perm := info.Mode().Perm(); perm&0077 != 0 {
+		return fmt.Errorf("%s 权限为 %04o，其他用户可以读取其中的密码，建议执行 chmod 600 %s", path, perm, path)
+	}
+	return nil
+}
+
+// countMarkdownFiles 统计目录下的Markdown文件数量
+func countMarkdownFiles(dir string) int {
+	matches, _ := filepath.Glob(filepath.Join(dir, "*.md"))
+	return len(matches)
+}
+
+// latestFile 返回目录中按名称排序的最后一个Markdown文件
+func latestFile(dir string) string {
+	matches, _ := filepath.Glob(filepath.Join(dir, "*.md"))
+	if len(matches) == 0 {
+		return ""
+	}
+	sort.Strings(matches)
+	return filepath.Base(matches[len(matches)-1])
+}
+
+// valueOrNone 空值显示为“无”
+func valueOrNone(value string) string {
+	if value == "" {
+		return "无"
+	}
+	return value
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"io/fs"
@@ -9,6 +10,7 @@ import (
 	"time"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"exporter-to-obsidian/internal/client"
 	"exporter-to-obsidian/internal/exporter"
@@ -19,17 +21,23 @@ import (
 )
 
 // preprocessTasks 预处理任务时间字段
+// 全天任务的日期以任务自身的时区为准，其他任务转换到 TIME_ZONE 时区
 func preprocessTasks(tasks []types.Task) {
 	for i := range tasks {
+		allDay := tasks[i].IsAllDay != nil && *tasks[i].IsAllDay
+		timeZone := ""
+		if tasks[i].TimeZone != nil {
+			timeZone = *tasks[i].TimeZone
+		}
 		if tasks[i].StartDate != nil {
-			if parsed := utils.ParseDateTime(*tasks[i].StartDate); parsed != nil {
+			if parsed := utils.ParseTaskDate(*tasks[i].StartDate, timeZone, allDay); parsed != nil {
 				tasks[i].ProcessedStartDate = parsed
 			}
 		}
 		if tasks[i].DueDate != nil {
-			if parsed := utils.ParseDateTime(*tasks[i].DueDate); parsed != nil {
+			if parsed := utils.ParseTaskDate(*tasks[i].DueDate, timeZone, allDay); parsed != nil {
 				// 如果是全天任务，将截止日期减去一天
-				if tasks[i].IsAllDay != nil && *tasks[i].IsAllDay && *tasks[i].StartDate != *tasks[i].DueDate {
+				if allDay && tasks[i].StartDate != nil && *tasks[i].StartDate != *tasks[i].DueDate {
 					adjusted := parsed.AddDate(0, 0, -1) // 减去一天
 					tasks[i].ProcessedDueDate = &adjusted
 				} else {
@@ -97,14 +105,78 @@ func getProjectColumns(client *client.Dida365Client, projectID string) ([]types.
 	return columns, nil
 }
 
+// projectColumns 单个项目的 Columns 获取结果
+type projectColumns struct {
+	columns []types.Column
+	err     error
+}
+
+// getAllProjectColumns 并发获取多个项目的 Columns，并发数由 DIDA365_CONCURRENCY 控制（默认 4）
+// 请求频率仍受客户端限流器约束
+func getAllProjectColumns(client *client.Dida365Client, projectIDs []string) map[string]projectColumns {
+	workers, err := strconv.Atoi(utils.GetEnvOrDefault("DIDA365_CONCURRENCY", "4"))
+	if err != nil || workers < 1 {
+		workers = 4
+	}
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results = make(map[string]projectColumns, len(projectIDs))
+		ids     = make(chan string)
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range ids {
+				columns, err := getProjectColumns(client, id)
+				mu.Lock()
+				results[id] = projectColumns{columns: columns, err: err}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, id := range projectIDs {
+		ids <- id
+	}
+	close(ids)
+	wg.Wait()
+
+	return results
+}
+
+// dida365Data 从滴答清单获取的数据
+type dida365Data struct {
+	projects       []types.Project
+	todoTasks      []types.Task
+	completedTasks []types.Task
+	noteProjects   []types.Project
+	notes          []types.Task
+	columns        []types.Column
+	tags           []types.Tag
+	// complete 项目分组与已完成任务均获取成功，可据此判断上游已删除的文件
+	complete bool
+	delta    client.SyncDelta
+}
+
 // getTasks 获取任务数据
-func getTasks(client *client.Dida365Client) ([]types.Project, []types.Task, []types.Task, []types.Project, []types.Task, []types.Column, error) {
+func getTasks(client *client.Dida365Client) (*dida365Data, error) {
 	log.Printf("正在获取滴答清单数据...")
 
 	// 获取所有数据
 	allData, err := client.GetAllData()
 	if err != nil {
-		return nil, nil, nil, nil, nil, nil, fmt.Errorf("获取所有数据失败: %v", err)
+		return nil, fmt.Errorf("获取所有数据失败: %w", err)
+	}
+	complete := true
+
+	delta := client.LastSyncDelta()
+	if delta.Full {
+		log.Printf("已执行全量同步")
+	} else {
+		log.Printf("增量同步：%d 个任务更新，%d 个任务完成，%d 个任务删除\n",
+			len(delta.Updated), len(delta.Closed), len(delta.Deleted))
 	}
 
 	// 解析项目数据
@@ -129,13 +201,26 @@ func getTasks(client *client.Dida365Client) ([]types.Project, []types.Task, []ty
 	}
 
 	if projectsData, ok := allData["projectProfiles"].([]interface{}); ok {
+		var projectIDs []string
+		for _, p := range projectsData {
+			if projectMap, ok := p.(map[string]interface{}); ok {
+				if id, ok := projectMap["id"].(string); ok {
+					projectIDs = append(projectIDs, id)
+				}
+			}
+		}
+		columnsByProject := getAllProjectColumns(client, projectIDs)
+
 		for _, p := range projectsData {
 			if projectMap, ok := p.(map[string]interface{}); ok {
 				project := types.Project{}
 				if id, ok := projectMap["id"].(string); ok {
 					project.ID = id
-					columns, _ := getProjectColumns(client, id)
-					if columns != nil {
+					result := columnsByProject[id]
+					if result.err != nil {
+						complete = false
+					}
+					if columns := result.columns; columns != nil {
 						all_columns = append(all_columns, columns...)
 						project.Columns = columns
 					}
@@ -156,6 +241,15 @@ func getTasks(client *client.Dida365Client) ([]types.Project, []types.Task, []ty
 		}
 	}
 
+	var tags []types.Tag
+	if tagsData, ok := allData["tags"].([]interface{}); ok {
+		for _, t := range tagsData {
+			if tagMap, ok := t.(map[string]interface{}); ok {
+				tags = append(tags, parseTagFromMap(tagMap))
+			}
+		}
+	}
+
 	if syncTaskBean, ok := allData["syncTaskBean"].(map[string]interface{}); ok {
 		if tasksData, ok := syncTaskBean["update"].([]interface{}); ok {
 			for _, t := range tasksData {
@@ -172,33 +266,48 @@ func getTasks(client *client.Dida365Client) ([]types.Project, []types.Task, []ty
 	}
 
 	// 获取已完成任务
-	today := time.Now()
+	today := utils.Now()
 	// 计算当前月份的开始日期
 	startDate := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, today.Location())
-	var endDate time.Time
-	if today.Month() == time.December {
-		endDate = time.Date(today.Year()+1, time.January, 1, 0, 0, 0, 0, today.Location()).Add(-time.Second)
-	} else {
-		endDate = time.Date(today.Year(), today.Month()+1, 1, 0, 0, 0, 0, today.Location()).Add(-time.Second)
-	}
-	completedTasks, err := client.GetCompletedTasks(
-		startDate.Format("2006-01-02 15:04:05"),
-		endDate.Format("2006-01-02 15:04:05"),
-		50,
-	)
+	endDate := startDate.AddDate(0, 1, 0).Add(-time.Second)
+	completedTasks, err := getCompletedTasks(client, startDate, endDate)
 	if err != nil {
 		log.Printf("获取已完成任务失败: %v\n", err)
 		completedTasks = []types.Task{}
+		complete = false
 	}
 
 	// 预处理任务时间字段
 	preprocessTasks(todoTasks)
-	preprocessTasks(completedTasks)
 
-	log.Printf("获取到 %d 个项目，%d 个待办任务，%d 个已完成任务，%d 个笔记项目，%d 个笔记, %d 个分组\n",
-		len(projects), len(todoTasks), len(completedTasks), len(note_projects), len(notes), len(all_columns))
+	log.Printf("获取到 %d 个项目，%d 个待办任务，%d 个已完成任务，%d 个笔记项目，%d 个笔记, %d 个分组, %d 个标签\n",
+		len(projects), len(todoTasks), len(completedTasks), len(note_projects), len(notes), len(all_columns), len(tags))
+
+	return &dida365Data{
+		projects:       projects,
+		todoTasks:      todoTasks,
+		completedTasks: completedTasks,
+		noteProjects:   note_projects,
+		notes:          notes,
+		columns:        all_columns,
+		tags:           tags,
+		complete:       complete,
+		delta:          delta,
+	}, nil
+}
 
-	return projects, todoTasks, completedTasks, note_projects, notes, all_columns, nil
+// getCompletedTasks 获取指定时间范围内的已完成任务，并预处理时间字段
+func getCompletedTasks(client *client.Dida365Client, startDate, endDate time.Time) ([]types.Task, error) {
+	completedTasks, err := client.GetCompletedTasks(
+		startDate.Format("2006-01-02 15:04:05"),
+		endDate.Format("2006-01-02 15:04:05"),
+		50,
+	)
+	if err != nil {
+		return nil, err
+	}
+	preprocessTasks(completedTasks)
+	return completedTasks, nil
 }
 
 // parseTaskFromMap 从map解析任务
@@ -234,6 +343,9 @@ func parseTaskFromMap(taskMap map[string]interface{}) types.Task {
 	if modifiedTime, ok := taskMap["modifiedTime"].(string); ok {
 		task.ModifiedTime = &modifiedTime
 	}
+	if etag, ok := taskMap["etag"].(string); ok {
+		task.Etag = &etag
+	}
 	if completedTime, ok := taskMap["completedTime"].(string); ok {
 		task.CompletedTime = &completedTime
 	}
@@ -243,6 +355,9 @@ func parseTaskFromMap(taskMap map[string]interface{}) types.Task {
 	if isAllDay, ok := taskMap["isAllDay"].(bool); ok {
 		task.IsAllDay = &isAllDay
 	}
+	if timeZone, ok := taskMap["timeZone"].(string); ok {
+		task.TimeZone = &timeZone
+	}
 	if kind, ok := taskMap["kind"].(string); ok {
 		task.Kind = &kind
 	}
@@ -253,6 +368,16 @@ func parseTaskFromMap(taskMap map[string]interface{}) types.Task {
 		task.RepeatFlag = &repeatFlag
 		// fmt.Printf("repeat_flag: %s\n", repeatFlag)
 	}
+	if repeatFirstDate, ok := taskMap["repeatFirstDate"].(string); ok {
+		task.RepeatFirstDate = &repeatFirstDate
+	}
+	if exDates, ok := taskMap["exDate"].([]interface{}); ok {
+		for _, exDate := range exDates {
+			if value, ok := exDate.(string); ok {
+				task.ExDate = append(task.ExDate, value)
+			}
+		}
+	}
 
 	// 解析任务项
 	if items, ok := taskMap["items"].([]interface{}); ok {
@@ -287,18 +412,61 @@ func parseTaskFromMap(taskMap map[string]interface{}) types.Task {
 		task.ParentID = &parentId
 	}
 
+	// 解析标签
+	if tags, ok := taskMap["tags"].([]interface{}); ok {
+		for _, tag := range tags {
+			if name, ok := tag.(string); ok {
+				task.Tags = append(task.Tags, name)
+			}
+		}
+	}
+
 	return task
 }
 
-// getHabits 获取习惯数据
-func getHabits(client *client.Dida365Client) ([]types.Habit, *types.HabitCheckinsResponse, int, error) {
+// parseTagFromMap 从map解析标签
+func parseTagFromMap(tagMap map[string]interface{}) types.Tag {
+	tag := types.Tag{}
+
+	if name, ok := tagMap["name"].(string); ok {
+		tag.Name = &name
+	}
+	if rawName, ok := tagMap["rawName"].(string); ok {
+		tag.RawName = &rawName
+	}
+	if label, ok := tagMap["label"].(string); ok {
+		tag.Label = &label
+	}
+	if sortOrder, ok := tagMap["sortOrder"].(float64); ok {
+		order := int(sortOrder)
+		tag.SortOrder = &order
+	}
+	if sortType, ok := tagMap["sortType"].(string); ok {
+		tag.SortType = &sortType
+	}
+	if color, ok := tagMap["color"].(string); ok {
+		tag.Color = &color
+	}
+	if etag, ok := tagMap["etag"].(string); ok {
+		tag.Etag = &etag
+	}
+	if parent, ok := tagMap["parent"].(string); ok && parent != "" {
+		tag.Parent = &parent
+	}
+
+	return tag
+}
+
+// getHabits 获取习惯数据，打卡记录从 after 当天开始获取
+// 习惯列表获取失败时返回 nil，导出器不会因此清理已有的习惯文件
+func getHabits(client *client.Dida365Client, after time.Time) ([]types.Habit, *types.HabitCheckinsResponse, error) {
 	log.Printf("正在获取习惯数据...")
 
 	// 获取习惯列表
 	habits_data, err := client.GetHabits()
 	if err != nil {
 		log.Printf("获取习惯列表失败: %v\n", err)
-		return []types.Habit{}, nil, 0, nil
+		return nil, nil, nil
 	}
 	var habits = []types.Habit{}
 	for _, habit := range habits_data {
@@ -308,14 +476,12 @@ func getHabits(client *client.Dida365Client) ([]types.Habit, *types.HabitCheckin
 	}
 
 	// 获取习惯打卡记录
-	todayStamp := utils.GetTodayStamp()
-
 	if len(habits) == 0 {
 		log.Printf("没有习惯打卡记录\n")
-		return []types.Habit{}, &types.HabitCheckinsResponse{}, todayStamp, nil
+		return []types.Habit{}, &types.HabitCheckinsResponse{}, nil
 	}
 
-	afterStamp := strconv.Itoa(todayStamp)
+	afterStamp := strconv.Itoa(utils.DateStamp(after.AddDate(0, 0, -1)))
 
 	var habitIDs []string
 	for _, habit := range habits {
@@ -331,32 +497,65 @@ func getHabits(client *client.Dida365Client) ([]types.Habit, *types.HabitCheckin
 	}
 
 	log.Printf("获取到 %d 个习惯\n", len(habits))
-	return habits, checkins, todayStamp, nil
+	return habits, checkins, nil
+}
+
+// periodStart 返回日期所在周与所在月中较早的第一天
+func periodStart(date time.Time) time.Time {
+	startOfWeek := utils.StartOfWeek(date)
+	startOfMonth := utils.StartOfDay(date).AddDate(0, 0, 1-date.In(utils.Location()).Day())
+	if startOfMonth.Before(startOfWeek) {
+		return startOfMonth
+	}
+	return startOfWeek
+}
+
+// checkinsStart 返回需要获取打卡记录的第一天：摘要所在的周与月、习惯笔记统计范围中最早的一天
+func checkinsStart(date time.Time) time.Time {
+	start := periodStart(date)
+	if history := exporter.HabitHistoryStart(); history.Before(start) {
+		return history
+	}
+	return start
 }
 
 // exportDida365 导出滴答清单数据
-func exportDida365() error {
+func exportDida365(opts exportOptions) error {
 	// 创建滴答清单客户端
 	client, err := client.NewDida365Client("", "")
 	if err != nil {
-		return fmt.Errorf("创建滴答清单客户端失败: %v", err)
+		return fmt.Errorf("创建滴答清单客户端失败: %w", err)
 	}
+	client.SetFullSync(opts.fullSync)
 
 	// 获取任务数据
-	projects, todoTasks, completedTasks, note_projects, notes, all_columns, err := getTasks(client)
+	data, err := getTasks(client)
 	if err != nil {
 		return err
 	}
 
-	// 获取习惯数据
-	habits, checkins, todayStamp, err := getHabits(client)
+	// 根据收集文件创建任务
+	if err := captureTasks(client, data, opts.outputDir); err != nil {
+		log.Printf("处理收集文件失败: %v", err)
+	}
+
+	// 获取习惯数据，打卡记录覆盖本周、本月与习惯笔记的统计范围
+	habits, checkins, err := getHabits(client, checkinsStart(utils.Now()))
 	if err != nil {
 		return err
 	}
 
 	// 创建导出器
-	outputDir := utils.GetEnvOrDefault("OUTPUT_DIR", ".")
-	exporter := exporter.NewDida365Exporter(projects, todoTasks, completedTasks, outputDir, note_projects, notes, all_columns)
+	exporter := exporter.NewDida365Exporter(data.projects, data.todoTasks, data.completedTasks, opts.outputDir, data.noteProjects, data.notes, data.columns)
+	exporter.SetTags(data.tags)
+	exporter.SetAttachmentDownloader(client)
+	exporter.SetTaskChecker(client)
+	exporter.SetHabits(habits, checkins)
+
+	// 将 Obsidian 中勾选的任务同步回滴答清单，失败时不覆盖本地文件，留待下次重试
+	if err := syncCheckboxes(client, exporter); err != nil {
+		return fmt.Errorf("同步任务勾选状态失败: %w", err)
+	}
 
 	// 导出项目任务
 	if err := exporter.ExportProjectTasks(); err != nil {
@@ -373,9 +572,19 @@ func exportDida365() error {
 		return fmt.Errorf("导出分组失败: %v", err)
 	}
 
+	// 导出标签
+	if err := exporter.ExportTags(); err != nil {
+		return fmt.Errorf("导出标签失败: %v", err)
+	}
+
+	// 导出习惯
+	if err := exporter.ExportHabits(); err != nil {
+		return fmt.Errorf("导出习惯失败: %v", err)
+	}
+
 	// 导出每日摘要
-	today := time.Now()
-	if err := exporter.ExportDailySummary(today, habits, checkins, todayStamp); err != nil {
+	today := utils.Now()
+	if err := exporter.ExportDailySummary(today); err != nil {
 		return fmt.Errorf("导出每日摘要失败: %v", err)
 	}
 
@@ -389,15 +598,194 @@ func exportDida365() error {
 		return fmt.Errorf("导出每月摘要失败: %v", err)
 	}
 
+	// 处理上游已删除的任务、笔记和分组
+	if err := exporter.CleanupStaleFiles(data.delta.Deleted, data.complete); err != nil {
+		return fmt.Errorf("清理已删除文件失败: %v", err)
+	}
+
+	// 记录本次导出的复选框状态
+	if err := exporter.SaveCheckboxState(); err != nil {
+		log.Printf("保存复选框状态失败: %v", err)
+	}
+
+	// 记录本次导出的任务与笔记文件，下次导出时跳过未变化的任务
+	if err := exporter.SaveState(); err != nil {
+		log.Printf("保存同步状态失败: %v", err)
+	}
+
+	// 保存习惯打卡与已完成任务的历史，供 regenerate 命令使用
+	if err := exporter.UpdateHistory(); err != nil {
+		log.Printf("保存历史数据失败: %v", err)
+	}
+
 	log.Printf("滴答清单数据导出完成")
 	return nil
 }
 
+// captureTasks 在滴答清单中创建收集文件里新记录的任务，并将对应行替换为任务链接
+func captureTasks(dida *client.Dida365Client, data *dida365Data, outputDir string) error {
+	capture, err := exporter.LoadCaptureFile(outputDir)
+	if err != nil || capture == nil {
+		return err
+	}
+
+	created := 0
+	for _, item := range capture.Items() {
+		task, err := dida.CreateTask(item.Task)
+		if err != nil {
+			log.Printf("创建任务 %s 失败: %v\n", *item.Task.Title, err)
+			continue
+		}
+
+		tasks := []types.Task{*task}
+		preprocessTasks(tasks)
+		data.todoTasks = append(data.todoTasks, tasks[0])
+		created++
+		// 每创建一个任务立即写回，中途失败时已创建的任务不会被重复创建
+		if err := capture.Resolve(item, tasks[0]); err != nil {
+			return fmt.Errorf("写回收集文件失败: %v", err)
+		}
+	}
+
+	if created > 0 {
+		log.Printf("已从收集文件创建 %d 个任务\n", created)
+	}
+	return nil
+}
+
+// syncCheckboxes 将 Obsidian 中勾选或取消勾选的任务同步到滴答清单
+func syncCheckboxes(dida *client.Dida365Client, dida365Exporter *exporter.Dida365Exporter) error {
+	changes := dida365Exporter.PendingCheckboxChanges()
+	if len(changes) == 0 {
+		return nil
+	}
+
+	var updates []client.TaskStatusChange
+	for _, change := range changes {
+		updates = append(updates, client.TaskStatusChange{Task: change.Task, Completed: change.Completed})
+		action := "重新打开"
+		if change.Completed {
+			action = "完成"
+		}
+		log.Printf("%s任务: %s\n", action, *change.Task.Title)
+	}
+
+	if err := dida.UpdateTaskStatus(updates); err != nil {
+		return err
+	}
+
+	dida365Exporter.ApplyCheckboxChanges(changes)
+	log.Printf("已同步 %d 个任务的勾选状态到滴答清单\n", len(changes))
+	return nil
+}
+
+// backfillDida365 补导指定日期范围内的已完成任务及日历摘要
+func backfillDida365(opts exportOptions, from, to time.Time) error {
+	// 创建滴答清单客户端
+	client, err := client.NewDida365Client("", "")
+	if err != nil {
+		return fmt.Errorf("创建滴答清单客户端失败: %w", err)
+	}
+
+	// 获取待办任务数据，用于生成摘要中的待办部分
+	data, err := getTasks(client)
+	if err != nil {
+		return err
+	}
+
+	// 获取指定范围内的已完成任务
+	completedTasks, err := getCompletedTasks(client, from, to.Add(24*time.Hour-time.Second))
+	if err != nil {
+		return fmt.Errorf("获取已完成任务失败: %w", err)
+	}
+	log.Printf("获取到 %s 至 %s 的 %d 个已完成任务\n", from.Format("2006-01-02"), to.Format("2006-01-02"), len(completedTasks))
+
+	// 打卡记录覆盖范围内的每日、每周、每月摘要以及习惯笔记的统计范围
+	habits, checkins, err := getHabits(client, checkinsStart(from))
+	if err != nil {
+		return err
+	}
+
+	exporter := exporter.NewDida365Exporter(data.projects, data.todoTasks, completedTasks, opts.outputDir, data.noteProjects, data.notes, data.columns)
+	exporter.SetTags(data.tags)
+	exporter.SetAttachmentDownloader(client)
+	exporter.SetHabits(habits, checkins)
+
+	// 导出项目任务（包含范围内的已完成任务）
+	if err := exporter.ExportProjectTasks(); err != nil {
+		return fmt.Errorf("导出项目任务失败: %v", err)
+	}
+
+	// 导出习惯
+	if err := exporter.ExportHabits(); err != nil {
+		return fmt.Errorf("导出习惯失败: %v", err)
+	}
+
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		if err := exporter.ExportDailySummary(day); err != nil {
+			return fmt.Errorf("导出每日摘要失败: %v", err)
+		}
+
+		// 每周、每月摘要在周期的第一天或范围的起点生成
+		if day.Equal(from) || day.Weekday() == time.Monday {
+			if err := exporter.ExportWeeklySummary(day); err != nil {
+				return fmt.Errorf("导出每周摘要失败: %v", err)
+			}
+		}
+		if day.Equal(from) || day.Day() == 1 {
+			if err := exporter.ExportMonthlySummary(day); err != nil {
+				return fmt.Errorf("导出每月摘要失败: %v", err)
+			}
+		}
+	}
+
+	// 记录本次导出的任务文件
+	if err := exporter.SaveState(); err != nil {
+		log.Printf("保存同步状态失败: %v", err)
+	}
+
+	// 保存习惯打卡与已完成任务的历史，供 regenerate 命令使用
+	if err := exporter.UpdateHistory(); err != nil {
+		log.Printf("保存历史数据失败: %v", err)
+	}
+
+	log.Printf("滴答清单数据补导完成")
+	return nil
+}
+
+// regenerateDida365 根据本地保存的历史数据重新生成指定日期范围内的每日摘要，不访问滴答清单
+func regenerateDida365(opts exportOptions, from, to time.Time) error {
+	history, err := exporter.LoadHistory()
+	if err != nil {
+		return err
+	}
+	log.Printf("历史数据：%d 个习惯，%d 个待办任务，%d 个已完成任务\n", len(history.Habits), len(history.TodoTasks), len(history.CompletedTasks))
+
+	// 处理后的开始、截止时间不会保存到历史数据中，与导出时一样重新计算
+	preprocessTasks(history.TodoTasks)
+	preprocessTasks(history.CompletedTasks)
+
+	exporter := exporter.NewDida365Exporter(nil, history.TodoTasks, history.CompletedTasks, opts.outputDir, nil, nil, nil)
+	exporter.SetHabits(history.Habits, &types.HabitCheckinsResponse{Checkins: history.Checkins})
+
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		if err := exporter.ExportDailySummary(day); err != nil {
+			return fmt.Errorf("导出每日摘要失败: %v", err)
+		}
+	}
+
+	log.Printf("已重新生成 %s 至 %s 的每日摘要", from.Format("2006-01-02"), to.Format("2006-01-02"))
+	return nil
+}
+
 // exportMemos 导出Memos数据
-func exportMemos() error {
+func exportMemos(opts exportOptions) error {
 	// 检查是否配置了Memos
 	memosAPI := os.Getenv("MEMOS_API")
-	memosToken := os.Getenv("MEMOS_TOKEN")
+	memosToken, err := utils.GetSecret("MEMOS_TOKEN")
+	if err != nil {
+		return err
+	}
 	if memosAPI == "" || memosToken == "" {
 		log.Printf("未配置Memos API，跳过Memos导出")
 		return nil
@@ -411,8 +799,14 @@ func exportMemos() error {
 		return fmt.Errorf("创建Memos客户端失败: %v", err)
 	}
 
-	// 获取Memos记录
-	records, err := client.FetchMemos(10, 0, "NORMAL")
+	// 获取回溯范围内的全部Memos记录，默认包含昨日，避免遗漏深夜的记录
+	today := utils.Now()
+	lookback, err := strconv.Atoi(utils.GetEnvOrDefault("MEMOS_LOOKBACK_DAYS", "1"))
+	if err != nil || lookback < 0 {
+		lookback = 1
+	}
+	since := time.Date(today.Year(), today.Month(), today.Day()-lookback, 0, 0, 0, 0, today.Location())
+	records, err := client.FetchAllMemos(since)
 	if err != nil {
 		return fmt.Errorf("获取Memos记录失败: %v", err)
 	}
@@ -420,21 +814,63 @@ func exportMemos() error {
 	log.Printf("获取到 %d 条Memos记录\n", len(records))
 
 	// 创建导出器
-	outputDir := utils.GetEnvOrDefault("OUTPUT_DIR", ".")
-	exporter := exporter.NewMemosExporter(records, outputDir)
+	exporter := exporter.NewMemosExporter(records, opts.outputDir)
+	exporter.SetResourceFetcher(client)
 
-	// 导出每日摘要
-	today := time.Now()
-	if err := exporter.ExportDailyMemos(today); err != nil {
+	// 导出回溯范围内每一天的摘要
+	if err := exporter.ExportRange(since, today); err != nil {
 		return fmt.Errorf("导出Memos每日摘要失败: %v", err)
 	}
 
+	// 记录本次导出的Memos文件
+	if err := exporter.SaveState(); err != nil {
+		log.Printf("保存同步状态失败: %v", err)
+	}
+
 	log.Printf("Memos数据导出完成")
 	return nil
 }
 
-func removeConflictFiles() {
-	searchPath := utils.GetEnvOrDefault("OUTPUT_DIR", ".")
+// backfillMemos 补导指定日期范围内的Memos摘要
+func backfillMemos(opts exportOptions, from, to time.Time) error {
+	memosAPI := os.Getenv("MEMOS_API")
+	memosToken, err := utils.GetSecret("MEMOS_TOKEN")
+	if err != nil {
+		return err
+	}
+	if memosAPI == "" || memosToken == "" {
+		log.Printf("未配置Memos API，跳过Memos补导")
+		return nil
+	}
+
+	client, err := client.NewMemosClient(memosAPI, memosToken)
+	if err != nil {
+		return fmt.Errorf("创建Memos客户端失败: %v", err)
+	}
+
+	records, err := client.FetchAllMemos(from)
+	if err != nil {
+		return fmt.Errorf("获取Memos记录失败: %v", err)
+	}
+	log.Printf("获取到 %s 以来的 %d 条Memos记录\n", from.Format("2006-01-02"), len(records))
+
+	exporter := exporter.NewMemosExporter(records, opts.outputDir)
+	exporter.SetResourceFetcher(client)
+
+	if err := exporter.ExportRange(from, to); err != nil {
+		return fmt.Errorf("导出Memos摘要失败: %v", err)
+	}
+
+	// 记录本次导出的Memos文件
+	if err := exporter.SaveState(); err != nil {
+		log.Printf("保存同步状态失败: %v", err)
+	}
+
+	log.Printf("Memos数据补导完成")
+	return nil
+}
+
+func removeConflictFiles(searchPath string) {
 	keyword := "sync-conflict"
 
 	_ = filepath.WalkDir(searchPath, func(path string, d fs.DirEntry, err error) error {
@@ -453,37 +889,46 @@ func removeConflictFiles() {
 	log.Printf("已删除冲突文件")
 }
 
-// runExport 执行一次数据导出
-func runExport() {
+// runExport 执行一次数据导出，任一数据源失败时返回错误
+func runExport(opts exportOptions) error {
 	log.Printf("开始导出数据...")
 
+	var failed []string
+	authFailed := false
+
 	// 导出滴答清单数据
-	if err := exportDida365(); err != nil {
-		log.Printf("导出滴答清单数据失败: %v", err)
+	if opts.sources[sourceDida365] {
+		if err := exportDida365(opts); err != nil {
+			log.Printf("导出滴答清单数据失败: %v", err)
+			failed = append(failed, sourceDida365)
+			authFailed = errors.Is(err, client.ErrAuthFailed)
+		}
 	}
 
 	// 导出Memos数据
-	if err := exportMemos(); err != nil {
-		log.Printf("导出Memos数据失败: %v", err)
+	if opts.sources[sourceMemos] {
+		if err := exportMemos(opts); err != nil {
+			log.Printf("导出Memos数据失败: %v", err)
+			failed = append(failed, sourceMemos)
+		}
 	}
 
 	log.Printf("数据导出完成")
 
-	removeConflictFiles()
+	removeConflictFiles(opts.outputDir)
+
+	if authFailed {
+		return fmt.Errorf("以下数据源导出失败: %s: %w", strings.Join(failed, ", "), client.ErrAuthFailed)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("以下数据源导出失败: %s", strings.Join(failed, ", "))
+	}
+	return nil
 }
 
 func main() {
 	// 加载环境变量
 	godotenv.Load()
 
-	// 创建定时器，每5分钟触发一次
-	ticker := time.NewTicker(5 * time.Minute)
-
-	// 立即执行第一次导出
-	runExport()
-
-	// 进入无限循环，等待定时器触发
-	for range ticker.C {
-		runExport()
-	}
+	os.Exit(run(os.Args[1:]))
 }
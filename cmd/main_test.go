@@ -0,0 +1,75 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"exporter-to-obsidian/internal/exporter"
+	"exporter-to-obsidian/internal/types"
+	"exporter-to-obsidian/internal/utils"
+)
+
+func TestRegenerateMatchesExportForMultiDayAllDayTask(t *testing.T) {
+	outputDir := t.TempDir()
+	t.Setenv("OUTPUT_DIR", outputDir)
+	t.Setenv("STATE_DIR", filepath.Join(outputDir, ".exporter"))
+	t.Setenv("TIME_ZONE", "Asia/Shanghai")
+
+	str := func(s string) *string { return &s }
+	allDay, status := true, 0
+	// 滴答清单中 1月2日至1月3日的全天任务，截止时间为次日零点
+	tasks := []types.Task{{
+		ID:        str("task1"),
+		ProjectID: str("project1"),
+		Title:     str("多日任务"),
+		Status:    &status,
+		IsAllDay:  &allDay,
+		TimeZone:  str("Asia/Shanghai"),
+		StartDate: str("2024-01-01T16:00:00.000+0000"),
+		DueDate:   str("2024-01-03T16:00:00.000+0000"),
+	}}
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, utils.Location())
+	to := from.AddDate(0, 0, 4)
+
+	preprocessTasks(tasks)
+	dida365Exporter := exporter.NewDida365Exporter(nil, tasks, nil, outputDir, nil, nil, nil)
+	exported := make(map[string]string)
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		if err := dida365Exporter.ExportDailySummary(day); err != nil {
+			t.Fatal(err)
+		}
+		exported[day.Format("2006-01-02")] = readDailySummary(t, outputDir, day)
+	}
+	if err := dida365Exporter.UpdateHistory(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.RemoveAll(filepath.Join(outputDir, "Calendar")); err != nil {
+		t.Fatal(err)
+	}
+	if err := regenerateDida365(exportOptions{outputDir: outputDir}, from, to); err != nil {
+		t.Fatal(err)
+	}
+
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		date := day.Format("2006-01-02")
+		if got := readDailySummary(t, outputDir, day); got != exported[date] {
+			t.Errorf("%s 重新生成的每日摘要与导出时不同\n导出:\n%s\n重新生成:\n%s", date, exported[date], got)
+		}
+	}
+	if exported["2024-01-04"] == exported["2024-01-03"] {
+		t.Errorf("截止日期之后的每日摘要不应包含该任务")
+	}
+}
+
+func readDailySummary(t *testing.T, outputDir string, day time.Time) string {
+	t.Helper()
+	content, err := os.ReadFile(filepath.Join(outputDir, "Calendar", "1.Daily", day.Format("2006-01-02")+"-Dida365.md"))
+	if err != nil {
+		return ""
+	}
+	return string(content)
+}
@@ -1,14 +1,17 @@
 package client
 
 import (
-	"crypto/tls" // 新增：用于TLS配置
 	"encoding/json"
 	"fmt"
 	"os"
+	"path"
 	"strings"
+	"sync"
 	"time" // 新增：用于时间处理
 
+	"exporter-to-obsidian/internal/state"
 	"exporter-to-obsidian/internal/types"
+	"exporter-to-obsidian/internal/utils"
 
 	"github.com/go-resty/resty/v2"
 	"github.com/joho/godotenv"
@@ -22,30 +25,50 @@ type Dida365Client struct {
 	client        *resty.Client
 	token         string
 	inboxID       string
-	lastLoginTime time.Time // 新增：存储上次登录时间
+	lastLoginTime time.Time        // 新增：存储上次登录时间
+	fullSync      bool             // 下次同步是否强制全量
+	lastDelta     SyncDelta        // 最近一次同步的变更
+	snapshot      *Dida365Snapshot // 最近一次同步后的本地快照
+	state         *state.Store     // 登录会话与同步检查点
+	mu            sync.Mutex       // 保护 token、inboxID 与 lastLoginTime
+	loginMu       sync.Mutex       // 保证会话失效时只重新登录一次
 }
 
+// stateSource 状态文件中滴答清单的数据源名称
+const stateSource = "dida365"
+
 // NewDida365Client 创建新的滴答清单客户端
 func NewDida365Client(username, password string) (*Dida365Client, error) {
 	// 加载.env文件
 	godotenv.Load()
 
+	var err error
 	if username == "" {
-		username = os.Getenv("DIDA365_USERNAME")
+		if username, err = utils.GetSecret("DIDA365_USERNAME"); err != nil {
+			return nil, err
+		}
 	}
 	if password == "" {
-		password = os.Getenv("DIDA365_PASSWORD")
+		if password, err = utils.GetSecret("DIDA365_PASSWORD"); err != nil {
+			return nil, err
+		}
 	}
 
 	if username == "" || password == "" {
-		return nil, fmt.Errorf("请提供账号信息。可以通过参数传入或设置环境变量：\nDIDA365_USERNAME: 你的滴答清单用户名/邮箱\nDIDA365_PASSWORD: 你的滴答清单密码")
+		return nil, fmt.Errorf("请提供账号信息。可以通过参数传入或设置环境变量（也可以用 *_FILE 指定保存该值的文件）：\nDIDA365_USERNAME: 你的滴答清单用户名/邮箱\nDIDA365_PASSWORD: 你的滴答清单密码")
+	}
+
+	httpClient, err := newHTTPClient(newRateLimiter())
+	if err != nil {
+		return nil, err
 	}
 
 	client := &Dida365Client{
 		username: username,
 		password: password,
 		baseURL:  "https://api.dida365.com/api/v2",
-		client:   resty.New().SetTLSClientConfig(&tls.Config{InsecureSkipVerify: true}), // 修改：添加跳过TLS验证
+		client:   httpClient,
+		state:    state.Open(),
 	}
 
 	// 设置默认请求头
@@ -54,8 +77,8 @@ func NewDida365Client(username, password string) (*Dida365Client, error) {
 		"x-device":   `{"platform":"web","os":"Windows 11","device":"Chrome 131.0.0.0","name":"","version":6246,"id":"674ea3c2a4f37a3f2c9b42d8","channel":"website","campaign":"","websocket":"67e7de9bf92b296c741567e0"}`,
 	})
 
-	// 尝试从环境变量加载token和上次登录时间
-	client.loadTokenFromEnv()
+	// 尝试从状态文件加载token和上次登录时间
+	client.loadSession()
 	if client.token == "" {
 		if err := client.Login(); err != nil {
 			return nil, err
@@ -69,15 +92,24 @@ func NewDida365Client(username, password string) (*Dida365Client, error) {
 			}
 		} else {
 			fmt.Println("使用本地保存的Token")
-			client.client.SetHeader("Cookie", fmt.Sprintf("t=%s", client.token))
 		}
 	}
 
 	return client, nil
 }
 
-// loadTokenFromEnv 从环境变量加载token和上次登录时间
-func (c *Dida365Client) loadTokenFromEnv() {
+// loadSession 从状态文件加载token、收集箱ID和上次登录时间
+// 状态文件中没有会话时读取旧版本写入 .env 的 DIDA365_TOKEN 等变量，之后只保存到状态文件
+func (c *Dida365Client) loadSession() {
+	if session, ok := c.state.Session(stateSource); ok {
+		if session.Username == "" || session.Username == c.username {
+			c.token = session.Token
+			c.inboxID = session.InboxID
+			c.lastLoginTime = session.LoginTime
+		}
+		return
+	}
+
 	c.token = os.Getenv("DIDA365_TOKEN")
 	c.inboxID = os.Getenv("DIDA365_INBOX_ID")
 	if c.token == "None" {
@@ -97,54 +129,15 @@ func (c *Dida365Client) loadTokenFromEnv() {
 	}
 }
 
-// saveTokenToEnv 保存token、上次登录时间到环境变量文件
-func (c *Dida365Client) saveTokenToEnv() error {
-	envFile := ".env"
-	if _, err := os.Stat(envFile); os.IsNotExist(err) {
-		// 如果.env文件不存在，创建一个
-		file, err := os.Create(envFile)
-		if err != nil {
-			return fmt.Errorf("创建.env文件失败: %v", err)
-		}
-		file.Close()
-	}
-
-	// 读取现有内容
-	content, err := os.ReadFile(envFile)
-	if err != nil {
-		return fmt.Errorf("读取.env文件失败: %v", err)
-	}
-
-	lines := strings.Split(string(content), "\n")
-	updatedToken := false
-	updatedInbox := false
-	updatedTime := false
-
-	// 更新或添加token、上次登录时间
-	for i, line := range lines {
-		if strings.HasPrefix(line, "DIDA365_TOKEN=") {
-			lines[i] = fmt.Sprintf("DIDA365_TOKEN=%s", c.token)
-			updatedToken = true
-		} else if strings.HasPrefix(line, "DIDA365_INBOX_ID=") {
-			lines[i] = fmt.Sprintf("DIDA365_INBOX_ID=%s", c.inboxID)
-			updatedInbox = true
-		} else if strings.HasPrefix(line, "DIDA365_LAST_LOGIN_TIME=") {
-			lines[i] = fmt.Sprintf("DIDA365_LAST_LOGIN_TIME=%s", time.Now().Format(time.RFC3339))
-			updatedTime = true
-		}
-	}
-
-	if !updatedToken {
-		lines = append(lines, fmt.Sprintf("DIDA365_TOKEN=%s", c.token))
-	}
-	if !updatedInbox {
-		lines = append(lines, fmt.Sprintf("DIDA365_INBOX_ID=%s", c.inboxID))
-	}
-	if !updatedTime {
-		lines = append(lines, fmt.Sprintf("DIDA365_LAST_LOGIN_TIME=%s", time.Now().Format(time.RFC3339)))
-	}
-
-	return os.WriteFile(envFile, []byte(strings.Join(lines, "\n")), 0644)
+// saveSession 保存token、收集箱ID和上次登录时间到状态文件
+func (c *Dida365Client) saveSession() error {
+	c.state.SetSession(stateSource, state.Session{
+		Username:  c.username,
+		Token:     c.token,
+		InboxID:   c.inboxID,
+		LoginTime: c.lastLoginTime,
+	})
+	return c.state.Save()
 }
 
 // Login 登录获取token并更新登录时间
@@ -165,6 +158,14 @@ func (c *Dida365Client) Login() error {
 	}
 
 	if resp.StatusCode() != 200 {
+		if isCredentialError(resp) {
+			// 清除失效的会话，下次运行时直接登录而不是继续使用旧的 Token
+			c.state.SetSession(stateSource, state.Session{})
+			if err := c.state.Save(); err != nil {
+				fmt.Printf("清除登录会话失败: %v\n", err)
+			}
+			return fmt.Errorf("%w，状态码: %d, 响应: %s", ErrAuthFailed, resp.StatusCode(), resp.String())
+		}
 		return fmt.Errorf("登录失败，状态码: %d, 响应: %s", resp.StatusCode(), resp.String())
 	}
 
@@ -183,14 +184,15 @@ func (c *Dida365Client) Login() error {
 		return fmt.Errorf("登录响应中未找到inboxId")
 	}
 
+	c.mu.Lock()
 	c.token = token
 	c.inboxID = inboxID
-	c.client.SetHeader("Cookie", fmt.Sprintf("t=%s", c.token))
 	c.lastLoginTime = time.Now() // 更新登录时间
+	c.mu.Unlock()
 
-	// 保存token和登录时间到环境变量文件
-	if err := c.saveTokenToEnv(); err != nil {
-		fmt.Printf("保存token到.env失败: %v\n", err)
+	// 保存token和登录时间到状态文件
+	if err := c.saveSession(); err != nil {
+		fmt.Printf("保存登录会话失败: %v\n", err)
 	}
 
 	return nil
@@ -198,11 +200,10 @@ func (c *Dida365Client) Login() error {
 
 // GetProjects 获取所有项目列表
 func (c *Dida365Client) GetProjects() ([]types.Project, error) {
-	resp, err := c.client.R().
-		Get(fmt.Sprintf("%s/projects", c.baseURL))
+	resp, err := c.do(resty.MethodGet, fmt.Sprintf("%s/projects", c.baseURL), nil)
 
 	if err != nil {
-		return nil, fmt.Errorf("获取项目列表失败: %v", err)
+		return nil, fmt.Errorf("获取项目列表失败: %w", err)
 	}
 
 	if resp.StatusCode() != 200 {
@@ -218,12 +219,63 @@ func (c *Dida365Client) GetProjects() ([]types.Project, error) {
 }
 
 // GetAllData 获取项目列表、任务列表、标签列表
+// 首次运行或需要全量同步时调用 /batch/check/0，之后使用状态文件中的检查点增量同步，
+// 并将变更合并到本地快照中，返回合并后的完整数据
 func (c *Dida365Client) GetAllData() (map[string]interface{}, error) {
-	resp, err := c.client.R().
-		Get(fmt.Sprintf("%s/batch/check/0", c.baseURL))
+	path := snapshotPath()
+	snapshot, ok := loadSnapshot(path)
 
+	interval, err := time.ParseDuration(utils.GetEnvOrDefault("DIDA365_FULL_SYNC_INTERVAL", "24h"))
 	if err != nil {
-		return nil, fmt.Errorf("获取所有数据失败: %v", err)
+		interval = 24 * time.Hour
+	}
+	checkpoint := c.state.Checkpoint(stateSource)
+	// 快照缺失时检查点之前的数据已经丢失，必须全量同步
+	full := c.fullSync || !ok || needsFullSync(checkpoint, interval)
+
+	checkPoint := int64(0)
+	if !full {
+		checkPoint = checkpoint.Value
+	}
+
+	result, err := c.batchCheck(checkPoint)
+	if err != nil {
+		return nil, err
+	}
+
+	c.lastDelta = snapshot.merge(result, full)
+	c.snapshot = snapshot
+	c.mu.Lock()
+	if snapshot.InboxID != "" && c.inboxID == "" {
+		c.inboxID = snapshot.InboxID
+	}
+	c.mu.Unlock()
+
+	// 检查点只在快照保存成功后更新，保证两者一致
+	if err := snapshot.save(path); err != nil {
+		fmt.Printf("保存本地快照失败: %v\n", err)
+		return snapshot.data(), nil
+	}
+	if value, ok := result["checkPoint"].(float64); ok {
+		checkpoint.Value = int64(value)
+	}
+	if full {
+		checkpoint.FullSync = time.Now()
+	}
+	c.state.SetCheckpoint(stateSource, checkpoint)
+	if err := c.state.Save(); err != nil {
+		fmt.Printf("保存同步检查点失败: %v\n", err)
+	}
+
+	return snapshot.data(), nil
+}
+
+// batchCheck 调用 /batch/check/{checkpoint} 获取自检查点以来的变更
+func (c *Dida365Client) batchCheck(checkPoint int64) (map[string]interface{}, error) {
+	resp, err := c.do(resty.MethodGet, fmt.Sprintf("%s/batch/check/%d", c.baseURL, checkPoint), nil)
+
+	if err != nil {
+		return nil, fmt.Errorf("获取所有数据失败: %w", err)
 	}
 
 	if resp.StatusCode() != 200 {
@@ -238,18 +290,75 @@ func (c *Dida365Client) GetAllData() (map[string]interface{}, error) {
 	return result, nil
 }
 
+// SetFullSync 设置下次同步是否忽略本地检查点执行全量同步
+func (c *Dida365Client) SetFullSync(full bool) {
+	c.fullSync = full
+}
+
+// LastSyncDelta 获取最近一次同步的变更
+func (c *Dida365Client) LastSyncDelta() SyncDelta {
+	return c.lastDelta
+}
+
 // GetCompletedTasks 获取已完成任务列表
+// 接口按完成时间倒序返回，每页最多 limit 条；以上一页最后一条的完成时间作为下一页的结束时间，
+// 直到取完 fromDate 至 toDate 之间的全部任务
 func (c *Dida365Client) GetCompletedTasks(fromDate, toDate string, limit int) ([]types.Task, error) {
-	resp, err := c.client.R().
-		SetQueryParams(map[string]string{
+	var tasks []types.Task
+	seen := make(map[string]bool)
+	cursor := toDate
+
+	for {
+		page, err := c.getCompletedTasksPage(fromDate, cursor, limit)
+		if err != nil {
+			return nil, err
+		}
+
+		added := 0
+		for _, task := range page {
+			if task.ID != nil {
+				if seen[*task.ID] {
+					continue
+				}
+				seen[*task.ID] = true
+			}
+			tasks = append(tasks, task)
+			added++
+		}
+
+		if len(page) < limit {
+			break
+		}
+
+		// 同一秒内完成的任务超过一页时无法继续翻页
+		last := page[len(page)-1]
+		if added == 0 || last.CompletedTime == nil {
+			fmt.Printf("已完成任务分页中断，截止于 %s\n", cursor)
+			break
+		}
+		next := utils.FormatTime(*last.CompletedTime, "2006-01-02 15:04:05")
+		if next == "" || next == cursor {
+			fmt.Printf("已完成任务分页中断，截止于 %s\n", cursor)
+			break
+		}
+		cursor = next
+	}
+
+	return tasks, nil
+}
+
+// getCompletedTasksPage 获取一页已完成任务
+func (c *Dida365Client) getCompletedTasksPage(fromDate, toDate string, limit int) ([]types.Task, error) {
+	resp, err := c.do(resty.MethodGet, fmt.Sprintf("%s/project/all/completed", c.baseURL), func(req *resty.Request) {
+		req.SetQueryParams(map[string]string{
 			"from":  fromDate,
 			"to":    toDate,
 			"limit": fmt.Sprintf("%d", limit),
-		}).
-		Get(fmt.Sprintf("%s/project/all/completed", c.baseURL))
+		})
+	})
 
 	if err != nil {
-		return nil, fmt.Errorf("获取已完成任务失败: %v", err)
+		return nil, fmt.Errorf("获取已完成任务失败: %w", err)
 	}
 
 	if resp.StatusCode() != 200 {
@@ -264,13 +373,43 @@ func (c *Dida365Client) GetCompletedTasks(fromDate, toDate string, limit int) ([
 	return tasks, nil
 }
 
+// TaskStatus 查询单个任务的当前状态（0 待办、2 已完成、-1 已放弃）
+// 任务不存在或已移入回收站时 exists 为 false
+func (c *Dida365Client) TaskStatus(projectID, taskID string) (int, bool, error) {
+	resp, err := c.do(resty.MethodGet, fmt.Sprintf("%s/task/%s", c.baseURL, taskID), func(req *resty.Request) {
+		req.SetQueryParam("projectId", projectID)
+	})
+	if err != nil {
+		return 0, false, fmt.Errorf("查询任务失败: %w", err)
+	}
+
+	if resp.StatusCode() == 404 || taskNotFoundErrorCodes[errorCode(resp)] {
+		return 0, false, nil
+	}
+	if resp.StatusCode() != 200 {
+		return 0, false, fmt.Errorf("查询任务失败，状态码: %d", resp.StatusCode())
+	}
+
+	var task struct {
+		ID      string `json:"id"`
+		Status  int    `json:"status"`
+		Deleted int    `json:"deleted"`
+	}
+	if err := json.Unmarshal(resp.Body(), &task); err != nil {
+		return 0, false, fmt.Errorf("解析任务失败: %v", err)
+	}
+	if task.ID == "" {
+		return 0, false, nil
+	}
+	return task.Status, task.Deleted == 0, nil
+}
+
 // GetHabits 获取习惯列表
 func (c *Dida365Client) GetHabits() ([]types.Habit, error) {
-	resp, err := c.client.R().
-		Get(fmt.Sprintf("%s/habits", c.baseURL))
+	resp, err := c.do(resty.MethodGet, fmt.Sprintf("%s/habits", c.baseURL), nil)
 
 	if err != nil {
-		return nil, fmt.Errorf("获取习惯列表失败: %v", err)
+		return nil, fmt.Errorf("获取习惯列表失败: %w", err)
 	}
 
 	if resp.StatusCode() != 200 {
@@ -292,12 +431,12 @@ func (c *Dida365Client) GetHabitsCheckins(afterStamp string, habitIDs []string)
 		"habitIds":   habitIDs,
 	}
 
-	resp, err := c.client.R().
-		SetBody(payload).
-		Post(fmt.Sprintf("%s/habitCheckins/query", c.baseURL))
+	resp, err := c.do(resty.MethodPost, fmt.Sprintf("%s/habitCheckins/query", c.baseURL), func(req *resty.Request) {
+		req.SetBody(payload)
+	})
 
 	if err != nil {
-		return nil, fmt.Errorf("获取习惯打卡失败: %v", err)
+		return nil, fmt.Errorf("获取习惯打卡失败: %w", err)
 	}
 
 	if resp.StatusCode() != 200 {
@@ -312,8 +451,30 @@ func (c *Dida365Client) GetHabitsCheckins(afterStamp string, habitIDs []string)
 	return &result, nil
 }
 
+// DownloadAttachment 使用登录会话下载任务或笔记的附件，返回内容与 Content-Type
+func (c *Dida365Client) DownloadAttachment(projectID, taskID, attachmentID, filename string) ([]byte, string, error) {
+	host := strings.TrimSuffix(c.baseURL, "/api/v2")
+	url := fmt.Sprintf("%s/api/v1/attachment/%s/%s/%s%s", host, projectID, taskID, attachmentID, path.Ext(filename))
+
+	resp, err := c.do(resty.MethodGet, url, func(req *resty.Request) {
+		req.SetHeader("Accept", "*/*")
+	})
+
+	if err != nil {
+		return nil, "", fmt.Errorf("下载附件失败: %w", err)
+	}
+
+	if resp.StatusCode() != 200 {
+		return nil, "", fmt.Errorf("下载附件失败，状态码: %d", resp.StatusCode())
+	}
+
+	return resp.Body(), resp.Header().Get("Content-Type"), nil
+}
+
 // GetInboxID 获取收集箱ID
 func (c *Dida365Client) GetInboxID() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	return c.inboxID
 }
 
@@ -321,11 +482,10 @@ func (c *Dida365Client) GetInboxID() string {
 func (c *Dida365Client) GetProjectColumns(projectID string) ([]types.Column, error) {
 	url := fmt.Sprintf("%s/column/project/%s", c.baseURL, projectID)
 	
-	resp, err := c.client.R().
-		Get(url)
+	resp, err := c.do(resty.MethodGet, url, nil)
 
 	if err != nil {
-		return nil, fmt.Errorf("获取项目列信息失败: %v", err)
+		return nil, fmt.Errorf("获取项目列信息失败: %w", err)
 	}
 
 	if resp.StatusCode() != 200 {
@@ -0,0 +1,107 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// ErrAuthFailed 滴答清单账号或密码错误，重新登录也无法恢复
+var ErrAuthFailed = errors.New("滴答清单账号或密码错误")
+
+// sessionErrorCodes 表示登录会话已失效的错误码
+var sessionErrorCodes = map[string]bool{
+	"user_not_sign_on": true,
+	"unauthorized":     true,
+}
+
+// taskNotFoundErrorCodes 查询任务时表示任务不存在的错误码
+var taskNotFoundErrorCodes = map[string]bool{
+	"task_not_found": true,
+	"not_found":      true,
+}
+
+// credentialErrorCodes 登录接口返回的账号或密码错误的错误码
+var credentialErrorCodes = map[string]bool{
+	"username_password_not_match":       true,
+	"username_not_exist":                true,
+	"incorrect_password_too_many_times": true,
+}
+
+// do 使用当前的登录会话发送请求，会话失效时重新登录一次后重试
+// build 用于设置请求参数与请求体，重试时会重新调用
+func (c *Dida365Client) do(method, url string, build func(req *resty.Request)) (*resty.Response, error) {
+	token := c.currentToken()
+	resp, err := c.send(method, url, token, build)
+	if err != nil || !isSessionError(resp) {
+		return resp, err
+	}
+
+	if err := c.relogin(token); err != nil {
+		return nil, err
+	}
+	return c.send(method, url, c.currentToken(), build)
+}
+
+// send 携带指定 Token 发送一次请求
+func (c *Dida365Client) send(method, url, token string, build func(req *resty.Request)) (*resty.Response, error) {
+	req := c.client.R().SetHeader("Cookie", fmt.Sprintf("t=%s", token))
+	if build != nil {
+		build(req)
+	}
+	return req.Execute(method, url)
+}
+
+// relogin 重新登录，expired 为失效的 Token
+// 并发的请求同时发现会话失效时只登录一次，其余请求直接使用新的 Token
+func (c *Dida365Client) relogin(expired string) error {
+	c.loginMu.Lock()
+	defer c.loginMu.Unlock()
+
+	if c.currentToken() != expired {
+		return nil
+	}
+	fmt.Println("登录会话已失效，重新登录")
+	return c.Login()
+}
+
+// currentToken 获取当前的登录 Token
+func (c *Dida365Client) currentToken() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.token
+}
+
+// isSessionError 判断响应是否表示登录会话已失效
+func isSessionError(resp *resty.Response) bool {
+	switch resp.StatusCode() {
+	case http.StatusOK:
+		return false
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return true
+	}
+	return sessionErrorCodes[errorCode(resp)]
+}
+
+// isCredentialError 判断登录失败是否因为账号或密码错误
+func isCredentialError(resp *resty.Response) bool {
+	switch resp.StatusCode() {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return true
+	}
+	return credentialErrorCodes[errorCode(resp)]
+}
+
+// errorCode 解析错误响应中的 errorCode
+func errorCode(resp *resty.Response) string {
+	var result struct {
+		ErrorCode string `json:"errorCode"`
+	}
+	if err := json.Unmarshal(resp.Body(), &result); err != nil {
+		return ""
+	}
+	return result.ErrorCode
+}
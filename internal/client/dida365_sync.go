@@ -0,0 +1,197 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"exporter-to-obsidian/internal/state"
+	"exporter-to-obsidian/internal/utils"
+)
+
+// Dida365Snapshot 滴答清单本地数据快照，保存合并后的数据，对应的检查点记录在状态文件中
+type Dida365Snapshot struct {
+	InboxID         string                            `json:"inboxId,omitempty"`
+	ProjectProfiles []interface{}                     `json:"projectProfiles"`
+	ProjectGroups   []interface{}                     `json:"projectGroups,omitempty"`
+	Tags            []interface{}                     `json:"tags,omitempty"`
+	Tasks           map[string]map[string]interface{} `json:"tasks"`
+}
+
+// SyncDelta 最近一次同步相对本地快照的变更
+type SyncDelta struct {
+	Full    bool     // 是否为全量同步
+	Updated []string // 新增或修改的任务ID
+	Closed  []string // 已完成的任务ID
+	Deleted []string // 已删除、移入回收站或已放弃的任务ID
+}
+
+// snapshotPath 快照文件路径
+func snapshotPath() string {
+	return filepath.Join(utils.GetStateDir(), "dida365-snapshot.json")
+}
+
+// loadSnapshot 读取本地快照，不存在或损坏时返回空快照，第二个返回值为 false
+func loadSnapshot(path string) (*Dida365Snapshot, bool) {
+	snapshot := &Dida365Snapshot{Tasks: make(map[string]map[string]interface{})}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return snapshot, false
+	}
+	if err := json.Unmarshal(content, snapshot); err != nil {
+		fmt.Printf("解析本地快照失败，将执行全量同步: %v\n", err)
+		return &Dida365Snapshot{Tasks: make(map[string]map[string]interface{})}, false
+	}
+	if snapshot.Tasks == nil {
+		snapshot.Tasks = make(map[string]map[string]interface{})
+	}
+	return snapshot, true
+}
+
+// save 原子地保存快照到文件，中途崩溃时不会留下残缺的快照
+func (s *Dida365Snapshot) save(path string) error {
+	content, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("序列化本地快照失败: %v", err)
+	}
+	if _, err := utils.WriteFile(path, content, 0600); err != nil {
+		return fmt.Errorf("保存本地快照失败: %v", err)
+	}
+	return nil
+}
+
+// needsFullSync 根据检查点判断是否需要执行全量同步
+func needsFullSync(checkpoint state.Checkpoint, interval time.Duration) bool {
+	if checkpoint.Value == 0 || checkpoint.FullSync.IsZero() {
+		return true
+	}
+	return interval > 0 && time.Since(checkpoint.FullSync) > interval
+}
+
+// merge 将 batch/check 的返回结果合并到快照中
+func (s *Dida365Snapshot) merge(result map[string]interface{}, full bool) SyncDelta {
+	delta := SyncDelta{Full: full}
+
+	if full {
+		s.Tasks = make(map[string]map[string]interface{})
+		s.ProjectProfiles = nil
+		s.ProjectGroups = nil
+		s.Tags = nil
+	}
+
+	if inboxID, ok := result["inboxId"].(string); ok && inboxID != "" {
+		s.InboxID = inboxID
+	}
+
+	// 增量同步只返回有变化的项目、项目分组和标签，按ID（标签按名称）合并到快照中；
+	// 增量结果中不包含已删除的项目、分组和标签，全量同步时整体替换后才会移除
+	if projects, ok := result["projectProfiles"].([]interface{}); ok {
+		s.ProjectProfiles = mergeByKey(s.ProjectProfiles, projects, "id")
+	}
+	if groups, ok := result["projectGroups"].([]interface{}); ok {
+		s.ProjectGroups = mergeByKey(s.ProjectGroups, groups, "id")
+	}
+	if tags, ok := result["tags"].([]interface{}); ok {
+		s.Tags = mergeByKey(s.Tags, tags, "name")
+	}
+
+	syncTaskBean, ok := result["syncTaskBean"].(map[string]interface{})
+	if !ok {
+		return delta
+	}
+
+	for _, key := range []string{"update", "add"} {
+		items, ok := syncTaskBean[key].([]interface{})
+		if !ok {
+			continue
+		}
+		for _, item := range items {
+			taskMap, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			id, ok := taskMap["id"].(string)
+			if !ok || id == "" {
+				continue
+			}
+
+			// 与全量同步保持一致：快照中只保留未完成的任务
+			deleted, _ := taskMap["deleted"].(float64)
+			status, _ := taskMap["status"].(float64)
+			switch {
+			case deleted != 0 || status < 0:
+				delete(s.Tasks, id)
+				delta.Deleted = append(delta.Deleted, id)
+			case status == 2:
+				delete(s.Tasks, id)
+				delta.Closed = append(delta.Closed, id)
+			default:
+				s.Tasks[id] = taskMap
+				delta.Updated = append(delta.Updated, id)
+			}
+		}
+	}
+
+	if items, ok := syncTaskBean["delete"].([]interface{}); ok {
+		for _, item := range items {
+			deleteMap, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if id, ok := deleteMap["taskId"].(string); ok && id != "" {
+				delete(s.Tasks, id)
+				delta.Deleted = append(delta.Deleted, id)
+			}
+		}
+	}
+
+	return delta
+}
+
+// data 将快照转换为与 batch/check 返回结构一致的数据
+func (s *Dida365Snapshot) data() map[string]interface{} {
+	tasks := make([]interface{}, 0, len(s.Tasks))
+	for _, task := range s.Tasks {
+		tasks = append(tasks, task)
+	}
+
+	return map[string]interface{}{
+		"inboxId":         s.InboxID,
+		"projectProfiles": s.ProjectProfiles,
+		"projectGroups":   s.ProjectGroups,
+		"tags":            s.Tags,
+		"syncTaskBean": map[string]interface{}{
+			"update": tasks,
+		},
+	}
+}
+
+// mergeByKey 按 key 字段合并对象列表，新数据覆盖旧数据，没有该字段的对象直接追加
+func mergeByKey(existing, updates []interface{}, key string) []interface{} {
+	index := make(map[string]int)
+	merged := make([]interface{}, 0, len(existing)+len(updates))
+	for _, item := range existing {
+		if itemMap, ok := item.(map[string]interface{}); ok {
+			if id, ok := itemMap[key].(string); ok {
+				index[id] = len(merged)
+			}
+		}
+		merged = append(merged, item)
+	}
+	for _, item := range updates {
+		if itemMap, ok := item.(map[string]interface{}); ok {
+			if id, ok := itemMap[key].(string); ok {
+				if i, exists := index[id]; exists {
+					merged[i] = item
+					continue
+				}
+				index[id] = len(merged)
+			}
+		}
+		merged = append(merged, item)
+	}
+	return merged
+}
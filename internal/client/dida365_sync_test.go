@@ -0,0 +1,91 @@
+package client
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSnapshotMergePartialPayload(t *testing.T) {
+	snapshot := &Dida365Snapshot{Tasks: make(map[string]map[string]interface{})}
+	snapshot.merge(map[string]interface{}{
+		"inboxId": "inbox1",
+		"projectProfiles": []interface{}{
+			map[string]interface{}{"id": "p1", "name": "工作"},
+			map[string]interface{}{"id": "p2", "name": "生活"},
+		},
+		"projectGroups": []interface{}{
+			map[string]interface{}{"id": "g1", "name": "分组一"},
+			map[string]interface{}{"id": "g2", "name": "分组二"},
+		},
+		"tags": []interface{}{
+			map[string]interface{}{"name": "a", "color": "#111"},
+			map[string]interface{}{"name": "b", "color": "#222"},
+		},
+		"syncTaskBean": map[string]interface{}{
+			"update": []interface{}{
+				map[string]interface{}{"id": "t1", "status": float64(0)},
+				map[string]interface{}{"id": "t2", "status": float64(0)},
+			},
+		},
+	}, true)
+
+	// 增量结果只包含有变化的对象
+	delta := snapshot.merge(map[string]interface{}{
+		"projectProfiles": []interface{}{
+			map[string]interface{}{"id": "p3", "name": "学习"},
+		},
+		"projectGroups": []interface{}{
+			map[string]interface{}{"id": "g2", "name": "新分组二"},
+		},
+		"tags": []interface{}{
+			map[string]interface{}{"name": "b", "color": "#333"},
+		},
+		"syncTaskBean": map[string]interface{}{
+			"update": []interface{}{
+				map[string]interface{}{"id": "t2", "status": float64(2)},
+			},
+			"delete": []interface{}{
+				map[string]interface{}{"taskId": "t3"},
+			},
+		},
+	}, false)
+
+	if got := fieldValues(snapshot.ProjectProfiles, "name"); !reflect.DeepEqual(got, []string{"工作", "生活", "学习"}) {
+		t.Errorf("projectProfiles = %v", got)
+	}
+	if got := fieldValues(snapshot.ProjectGroups, "name"); !reflect.DeepEqual(got, []string{"分组一", "新分组二"}) {
+		t.Errorf("projectGroups = %v", got)
+	}
+	if got := fieldValues(snapshot.Tags, "color"); !reflect.DeepEqual(got, []string{"#111", "#333"}) {
+		t.Errorf("tags = %v", got)
+	}
+	if snapshot.InboxID != "inbox1" {
+		t.Errorf("inboxId = %q", snapshot.InboxID)
+	}
+	if _, ok := snapshot.Tasks["t1"]; !ok || len(snapshot.Tasks) != 1 {
+		t.Errorf("tasks = %v", snapshot.Tasks)
+	}
+	if delta.Full || !reflect.DeepEqual(delta.Closed, []string{"t2"}) || !reflect.DeepEqual(delta.Deleted, []string{"t3"}) {
+		t.Errorf("delta = %+v", delta)
+	}
+
+	// 全量同步整体替换，移除已删除的项目、分组和标签
+	snapshot.merge(map[string]interface{}{
+		"projectProfiles": []interface{}{map[string]interface{}{"id": "p1", "name": "工作"}},
+		"tags":            []interface{}{map[string]interface{}{"name": "a", "color": "#111"}},
+	}, true)
+	if got := fieldValues(snapshot.ProjectProfiles, "name"); !reflect.DeepEqual(got, []string{"工作"}) {
+		t.Errorf("full projectProfiles = %v", got)
+	}
+	if len(snapshot.ProjectGroups) != 0 || len(snapshot.Tags) != 1 || len(snapshot.Tasks) != 0 {
+		t.Errorf("full sync kept %v %v %v", snapshot.ProjectGroups, snapshot.Tags, snapshot.Tasks)
+	}
+}
+
+func fieldValues(items []interface{}, key string) []string {
+	var values []string
+	for _, item := range items {
+		values = append(values, item.(map[string]interface{})[key].(string))
+	}
+	return values
+}
@@ -0,0 +1,140 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"exporter-to-obsidian/internal/types"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// TaskStatusChange 任务完成状态的变更
+type TaskStatusChange struct {
+	Task      types.Task
+	Completed bool
+}
+
+// CreateTask 调用 /task 创建任务，返回滴答清单保存后的任务
+func (c *Dida365Client) CreateTask(task types.Task) (*types.Task, error) {
+	if task.ProjectID == nil || *task.ProjectID == "" {
+		inboxID := c.GetInboxID()
+		task.ProjectID = &inboxID
+	}
+
+	resp, err := c.do(resty.MethodPost, fmt.Sprintf("%s/task", c.baseURL), func(req *resty.Request) {
+		req.SetBody(task)
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("创建任务失败: %w", err)
+	}
+
+	if resp.StatusCode() != 200 {
+		return nil, fmt.Errorf("创建任务失败，状态码: %d, 响应: %s", resp.StatusCode(), resp.String())
+	}
+
+	var created types.Task
+	if err := json.Unmarshal(resp.Body(), &created); err != nil {
+		return nil, fmt.Errorf("解析创建任务响应失败: %v", err)
+	}
+	if created.ID == nil {
+		return nil, fmt.Errorf("创建任务响应中未找到任务ID")
+	}
+
+	return &created, nil
+}
+
+// UpdateTasks 调用 /batch/task 批量更新任务
+func (c *Dida365Client) UpdateTasks(tasks []map[string]interface{}) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	payload := map[string]interface{}{
+		"add":    []interface{}{},
+		"update": tasks,
+		"delete": []interface{}{},
+	}
+
+	resp, err := c.do(resty.MethodPost, fmt.Sprintf("%s/batch/task", c.baseURL), func(req *resty.Request) {
+		req.SetBody(payload)
+	})
+
+	if err != nil {
+		return fmt.Errorf("更新任务失败: %w", err)
+	}
+
+	if resp.StatusCode() != 200 {
+		return fmt.Errorf("更新任务失败，状态码: %d, 响应: %s", resp.StatusCode(), resp.String())
+	}
+
+	var result struct {
+		ID2Error map[string]interface{} `json:"id2error"`
+	}
+	if err := json.Unmarshal(resp.Body(), &result); err != nil {
+		return fmt.Errorf("解析更新任务响应失败: %v", err)
+	}
+	if len(result.ID2Error) > 0 {
+		return fmt.Errorf("部分任务更新失败: %v", result.ID2Error)
+	}
+
+	return nil
+}
+
+// UpdateTaskStatus 将任务标记为已完成或重新打开，重复任务会被跳过
+// 优先以本地快照中的原始任务数据为基础，避免覆盖未解析的字段
+func (c *Dida365Client) UpdateTaskStatus(changes []TaskStatusChange) error {
+	now := time.Now().UTC().Format("2006-01-02T15:04:05.000+0000")
+
+	var updates []map[string]interface{}
+	for _, change := range changes {
+		if change.Task.ID == nil {
+			continue
+		}
+		if change.Task.RepeatFlag != nil && *change.Task.RepeatFlag != "" {
+			// 直接修改状态会结束整个重复序列，重复任务只能在滴答清单中完成
+			fmt.Printf("跳过重复任务 %s 的状态更新\n", *change.Task.ID)
+			continue
+		}
+
+		task, err := c.rawTask(change.Task)
+		if err != nil {
+			return err
+		}
+		if change.Completed {
+			task["status"] = 2
+			task["completedTime"] = now
+		} else {
+			task["status"] = 0
+			task["completedTime"] = nil
+		}
+		task["modifiedTime"] = now
+		updates = append(updates, task)
+	}
+
+	return c.UpdateTasks(updates)
+}
+
+// rawTask 获取任务的原始数据，快照中不存在时由解析后的任务转换
+func (c *Dida365Client) rawTask(task types.Task) (map[string]interface{}, error) {
+	raw := make(map[string]interface{})
+	if c.snapshot != nil {
+		if existing, ok := c.snapshot.Tasks[*task.ID]; ok {
+			for k, v := range existing {
+				raw[k] = v
+			}
+			return raw, nil
+		}
+	}
+
+	content, err := json.Marshal(task)
+	if err != nil {
+		return nil, fmt.Errorf("序列化任务失败: %v", err)
+	}
+	if err := json.Unmarshal(content, &raw); err != nil {
+		return nil, fmt.Errorf("序列化任务失败: %v", err)
+	}
+	return raw, nil
+}
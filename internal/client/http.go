@@ -0,0 +1,161 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"exporter-to-obsidian/internal/utils"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// 重试的等待时间范围，实际等待时间按指数退避并加入随机抖动
+const (
+	retryWaitTime    = time.Second
+	retryMaxWaitTime = time.Minute
+)
+
+// newHTTPClient 创建校验服务器证书的 HTTP 客户端，limiter 为客户端共用的限流器，为 nil 时不限流
+// TLS_CA_BUNDLE 指定额外信任的 CA 证书文件（PEM 格式），用于自签名证书的 Memos 服务器或企业代理，系统证书仍然有效
+// HTTP_TIMEOUT 为单次请求的超时时间（默认 60s），HTTP_RETRIES 为网络错误、429 与 5xx 响应的重试次数（默认 3）
+func newHTTPClient(limiter *rateLimiter) (*resty.Client, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if bundle := os.Getenv("TLS_CA_BUNDLE"); bundle != "" {
+		pem, err := os.ReadFile(bundle)
+		if err != nil {
+			return nil, fmt.Errorf("读取CA证书失败: %v", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("CA证书文件中没有有效的PEM证书: %s", bundle)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	timeout, err := time.ParseDuration(utils.GetEnvOrDefault("HTTP_TIMEOUT", "60s"))
+	if err != nil || timeout <= 0 {
+		timeout = 60 * time.Second
+	}
+	retries, err := strconv.Atoi(utils.GetEnvOrDefault("HTTP_RETRIES", "3"))
+	if err != nil || retries < 0 {
+		retries = 3
+	}
+
+	client := resty.New().
+		SetTLSClientConfig(tlsConfig).
+		SetTimeout(timeout).
+		SetRetryCount(retries).
+		SetRetryWaitTime(retryWaitTime).
+		SetRetryMaxWaitTime(retryMaxWaitTime).
+		SetRetryAfter(retryAfter).
+		AddRetryCondition(shouldRetry).
+		AddRetryHook(func(resp *resty.Response, err error) {
+			if err != nil {
+				fmt.Printf("请求失败，稍后重试: %v\n", err)
+				return
+			}
+			fmt.Printf("请求 %s 返回状态码 %d，稍后重试\n", resp.Request.URL, resp.StatusCode())
+		})
+
+	// 每次发送请求（包括重试）前等待限流器的令牌
+	if limiter != nil {
+		client.OnBeforeRequest(func(*resty.Client, *resty.Request) error {
+			limiter.Wait()
+			return nil
+		})
+	}
+
+	return client, nil
+}
+
+// shouldRetry 判断请求是否需要重试
+// 网络错误、429 与 5xx 响应会重试；POST 等非幂等请求只在服务器明确未处理的 429、503 时重试，避免重复创建任务
+func shouldRetry(resp *resty.Response, err error) bool {
+	if resp == nil || resp.Request == nil {
+		return false
+	}
+	idempotent := resp.Request.Method != resty.MethodPost && resp.Request.Method != resty.MethodPatch
+
+	if err != nil {
+		return idempotent
+	}
+	switch status := resp.StatusCode(); {
+	case status == http.StatusTooManyRequests, status == http.StatusServiceUnavailable:
+		return true
+	case status >= 500:
+		return idempotent
+	}
+	return false
+}
+
+// retryAfter 根据 Retry-After 响应头计算重试前的等待时间
+// 没有该响应头时返回 0，使用带随机抖动的指数退避
+func retryAfter(_ *resty.Client, resp *resty.Response) (time.Duration, error) {
+	value := resp.Header().Get("Retry-After")
+	if value == "" {
+		return 0, nil
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(t); wait > 0 {
+			return wait, nil
+		}
+	}
+	return 0, nil
+}
+
+// rateLimiter 令牌桶限流器，同一客户端的所有请求共用
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // 每秒补充的令牌数
+	burst  float64 // 令牌桶容量
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter 根据 HTTP_RATE_LIMIT（每秒请求数，默认 5）与 HTTP_RATE_BURST（突发请求数，默认 10）创建限流器
+// HTTP_RATE_LIMIT 为 0 时不限流，返回 nil
+func newRateLimiter() *rateLimiter {
+	rate, err := strconv.ParseFloat(utils.GetEnvOrDefault("HTTP_RATE_LIMIT", "5"), 64)
+	if err != nil || rate < 0 {
+		rate = 5
+	}
+	if rate == 0 {
+		return nil
+	}
+	burst, err := strconv.ParseFloat(utils.GetEnvOrDefault("HTTP_RATE_BURST", "10"), 64)
+	if err != nil || burst < 1 {
+		burst = 10
+	}
+	return &rateLimiter{rate: rate, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// Wait 取出一个令牌，令牌不足时等待补充
+// 令牌数允许为负，表示已被预约，并发的请求按到达顺序依次等待
+func (l *rateLimiter) Wait() {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	l.tokens--
+	wait := time.Duration(0)
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	time.Sleep(wait)
+}
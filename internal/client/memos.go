@@ -1,22 +1,80 @@
 package client
 
 import (
-	"crypto/tls" // 新增：用于TLS配置
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"exporter-to-obsidian/internal/types"
+	"exporter-to-obsidian/internal/utils"
 
 	"github.com/go-resty/resty/v2"
 	"github.com/joho/godotenv"
 )
 
+// Memos API 版本
+const (
+	memosAPILegacy = "legacy" // v0.21 及之前的 limit/offset 接口
+	memosAPIV1     = "v1"     // v0.22 及之后的 /api/v1/memos 接口
+)
+
+// memosPageSize 分页获取时每页的记录数
+const memosPageSize = 100
+
 // MemosClient Memos API客户端
 type MemosClient struct {
-	apiURL string
-	token  string
-	client *resty.Client
+	apiURL     string
+	baseURL    string // 服务器地址，用于访问 v1 接口
+	token      string
+	client     *resty.Client
+	external   *resty.Client // 下载外部链接附件，不携带令牌
+	apiVersion string        // 服务器接口版本，为空时在首次请求前检测
+	apiQuery   url.Values    // MEMOS_API 中的查询参数，v1 接口请求时一并发送
+	user       string        // 令牌所属的用户，如 users/1
+
+	creatorFilterUnsupported bool // 服务器不支持按创建者过滤，在本地筛选
+}
+
+// memosV1Resource v1 接口返回的资源（附件）
+type memosV1Resource struct {
+	Name         string `json:"name"`
+	UID          string `json:"uid"`
+	Filename     string `json:"filename"`
+	ExternalLink string `json:"externalLink"`
+	Type         string `json:"type"`
+	Size         string `json:"size"` // int64 按字符串编码
+}
+
+// memosV1User v1 接口返回的用户
+type memosV1User struct {
+	Name     string `json:"name"` // users/{id}
+	Username string `json:"username"`
+}
+
+// memosV1Memo v1 接口返回的单条记录
+type memosV1Memo struct {
+	Name        string            `json:"name"`
+	UID         string            `json:"uid"`
+	Creator     string            `json:"creator"`   // users/{id}
+	RowStatus   string            `json:"rowStatus"` // v0.22 使用 rowStatus，之后改为 state
+	State       string            `json:"state"`
+	CreateTime  string            `json:"createTime"`
+	UpdateTime  string            `json:"updateTime"`
+	DisplayTime string            `json:"displayTime"`
+	Content     string            `json:"content"`
+	Pinned      bool              `json:"pinned"`
+	Resources   []memosV1Resource `json:"resources"`
+	Attachments []memosV1Resource `json:"attachments"` // v0.25 起资源改名为附件
+}
+
+// memosV1ListResponse v1 接口的分页响应
+type memosV1ListResponse struct {
+	Memos         []memosV1Memo `json:"memos"`
+	NextPageToken string        `json:"nextPageToken"`
 }
 
 // NewMemosClient 创建新的Memos客户端
@@ -28,17 +86,38 @@ func NewMemosClient(apiURL, token string) (*MemosClient, error) {
 		apiURL = os.Getenv("MEMOS_API")
 	}
 	if token == "" {
-		token = os.Getenv("MEMOS_TOKEN")
+		var err error
+		if token, err = utils.GetSecret("MEMOS_TOKEN"); err != nil {
+			return nil, err
+		}
 	}
 
 	if apiURL == "" || token == "" {
 		return nil, fmt.Errorf("请提供Memos API URL和Token")
 	}
 
+	// 两个客户端共用一个限流器
+	limiter := newRateLimiter()
+	httpClient, err := newHTTPClient(limiter)
+	if err != nil {
+		return nil, err
+	}
+	// 外部链接的附件使用单独的客户端，不携带令牌
+	external, err := newHTTPClient(limiter)
+	if err != nil {
+		return nil, err
+	}
+
 	client := &MemosClient{
-		apiURL: apiURL,
-		token:  token,
-		client: resty.New().SetTLSClientConfig(&tls.Config{InsecureSkipVerify: true}), // 修改：添加跳过TLS验证
+		apiURL:     apiURL,
+		baseURL:    memosBaseURL(apiURL),
+		token:      token,
+		client:     httpClient,
+		external:   external,
+		apiVersion: os.Getenv("MEMOS_API_VERSION"),
+	}
+	if u, err := url.Parse(apiURL); err == nil {
+		client.apiQuery = u.Query()
 	}
 
 	// 设置默认请求头
@@ -50,6 +129,329 @@ func NewMemosClient(apiURL, token string) (*MemosClient, error) {
 	return client, nil
 }
 
+// memosBaseURL 从 MEMOS_API 中提取服务器地址，去掉 /api/ 及之后的路径
+func memosBaseURL(apiURL string) string {
+	u, err := url.Parse(apiURL)
+	if err != nil {
+		return strings.TrimSuffix(apiURL, "/")
+	}
+	if idx := strings.Index(u.Path, "/api/"); idx >= 0 {
+		u.Path = u.Path[:idx]
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	u.RawQuery = ""
+	u.Fragment = ""
+	return u.String()
+}
+
+// APIVersion 检测服务器接口版本：/api/v1/memos 返回 {"memos": [...]} 时为 v1，否则使用旧接口
+func (c *MemosClient) APIVersion() string {
+	if c.apiVersion != "" {
+		return c.apiVersion
+	}
+
+	c.apiVersion = memosAPILegacy
+	resp, err := c.client.R().
+		SetQueryParam("pageSize", "1").
+		Get(c.baseURL + "/api/v1/memos")
+	if err == nil && resp.StatusCode() == 200 {
+		var probe map[string]json.RawMessage
+		if json.Unmarshal(resp.Body(), &probe) == nil {
+			if _, ok := probe["memos"]; ok {
+				c.apiVersion = memosAPIV1
+			}
+		}
+	}
+
+	fmt.Printf("Memos 接口版本: %s\n", c.apiVersion)
+	return c.apiVersion
+}
+
+// Ping 使用检测到的接口版本获取一条记录，检查服务器地址与令牌是否可用，返回接口版本
+func (c *MemosClient) Ping() (string, error) {
+	version := c.APIVersion()
+	if version != memosAPIV1 {
+		_, err := c.FetchMemos(1, 0, "NORMAL")
+		return version, err
+	}
+
+	// 未登录时列表接口仍会返回公开的记录，通过获取当前用户校验令牌
+	user, err := c.currentUser()
+	if err != nil {
+		return version, err
+	}
+	_, err = c.listMemosV1(1, "", user)
+	return version, err
+}
+
+// currentUser 获取令牌所属的用户，如 users/1
+// v0.22 至 v0.24 使用 POST /api/v1/auth/status，之后的版本使用 GET /api/v1/auth/sessions/current
+func (c *MemosClient) currentUser() (string, error) {
+	if c.user != "" {
+		return c.user, nil
+	}
+
+	resp, err := c.client.R().Post(c.baseURL + "/api/v1/auth/status")
+	if err != nil {
+		return "", fmt.Errorf("获取Memos当前用户失败: %v", err)
+	}
+	var user memosV1User
+	if resp.StatusCode() == 200 {
+		if err := json.Unmarshal(resp.Body(), &user); err != nil {
+			return "", fmt.Errorf("解析Memos当前用户失败: %v", err)
+		}
+	} else {
+		resp, err = c.client.R().Get(c.baseURL + "/api/v1/auth/sessions/current")
+		if err != nil {
+			return "", fmt.Errorf("获取Memos当前用户失败: %v", err)
+		}
+		var session struct {
+			User memosV1User `json:"user"`
+		}
+		if resp.StatusCode() == 200 {
+			if err := json.Unmarshal(resp.Body(), &session); err != nil {
+				return "", fmt.Errorf("解析Memos当前用户失败: %v", err)
+			}
+		}
+		user = session.User
+	}
+	if user.Name == "" {
+		return "", fmt.Errorf("获取Memos当前用户失败，状态码: %d", resp.StatusCode())
+	}
+
+	c.user = user.Name
+	return c.user, nil
+}
+
+// listMemosV1 获取一页记录，保留 MEMOS_API 中的查询参数，creator 不为空时只获取该用户创建的记录
+// 服务器不支持按创建者过滤时返回的记录由调用方按 creator 字段筛选
+func (c *MemosClient) listMemosV1(pageSize int, pageToken, creator string) (*memosV1ListResponse, error) {
+	params := url.Values{}
+	for key, values := range c.apiQuery {
+		params[key] = append([]string(nil), values...)
+	}
+	params.Set("pageSize", strconv.Itoa(pageSize))
+	if pageToken != "" {
+		params.Set("pageToken", pageToken)
+	}
+	filter := params.Get("filter")
+	if creator != "" && !c.creatorFilterUnsupported {
+		params.Set("filter", memosCreatorFilter(filter, creator))
+	}
+
+	resp, err := c.client.R().
+		SetQueryParamsFromValues(params).
+		Get(c.baseURL + "/api/v1/memos")
+	if err != nil {
+		return nil, fmt.Errorf("获取Memos数据失败: %v", err)
+	}
+
+	if resp.StatusCode() == 400 && creator != "" && !c.creatorFilterUnsupported {
+		fmt.Printf("Memos 服务器不支持按创建者过滤，改为在本地筛选\n")
+		c.creatorFilterUnsupported = true
+		return c.listMemosV1(pageSize, pageToken, creator)
+	}
+	if resp.StatusCode() != 200 {
+		return nil, fmt.Errorf("获取Memos数据失败，状态码: %d", resp.StatusCode())
+	}
+
+	var page memosV1ListResponse
+	if err := json.Unmarshal(resp.Body(), &page); err != nil {
+		return nil, fmt.Errorf("解析Memos数据失败: %v", err)
+	}
+	return &page, nil
+}
+
+// memosCreatorFilter 在 MEMOS_API 已有的 filter 上增加创建者条件
+func memosCreatorFilter(filter, creator string) string {
+	condition := fmt.Sprintf("creator == '%s'", creator)
+	if filter == "" {
+		return condition
+	}
+	return fmt.Sprintf("(%s) && %s", filter, condition)
+}
+
+// FetchAllMemos 分页获取 since 之后创建的全部正常状态的记录，since 为零值时获取全部
+// 置顶记录不受时间排序影响，始终会被完整遍历
+func (c *MemosClient) FetchAllMemos(since time.Time) ([]types.MemosRecord, error) {
+	if c.APIVersion() == memosAPIV1 {
+		return c.fetchAllMemosV1(since)
+	}
+	return c.fetchAllMemosLegacy(since)
+}
+
+// fetchAllMemosLegacy 使用 limit/offset 分页获取记录
+func (c *MemosClient) fetchAllMemosLegacy(since time.Time) ([]types.MemosRecord, error) {
+	var records []types.MemosRecord
+	for offset := 0; ; offset += memosPageSize {
+		page, err := c.FetchMemos(memosPageSize, offset, "NORMAL")
+		if err != nil {
+			return nil, err
+		}
+
+		done := len(page) < memosPageSize
+		for _, record := range page {
+			if !since.IsZero() && record.CreatedTs != nil && time.Unix(*record.CreatedTs, 0).Before(since) {
+				if record.Pinned == nil || !*record.Pinned {
+					done = true
+				}
+				continue
+			}
+			records = append(records, record)
+		}
+		if done {
+			return records, nil
+		}
+	}
+}
+
+// fetchAllMemosV1 使用 pageToken 分页获取令牌所属用户创建的记录，共享实例上其他用户的公开记录不会被导出
+func (c *MemosClient) fetchAllMemosV1(since time.Time) ([]types.MemosRecord, error) {
+	user, err := c.currentUser()
+	if err != nil {
+		return nil, err
+	}
+
+	var records []types.MemosRecord
+	pageToken := ""
+	for {
+		page, err := c.listMemosV1(memosPageSize, pageToken, user)
+		if err != nil {
+			return nil, err
+		}
+
+		done := page.NextPageToken == "" || page.NextPageToken == pageToken
+		for _, memo := range page.Memos {
+			if memo.Creator != "" && memo.Creator != user {
+				continue
+			}
+			record := memo.record()
+			if record.RowStatus != nil && *record.RowStatus != "NORMAL" {
+				continue
+			}
+			if !since.IsZero() && record.CreatedTs != nil && time.Unix(*record.CreatedTs, 0).Before(since) {
+				if !memo.Pinned {
+					done = true
+				}
+				continue
+			}
+			records = append(records, record)
+		}
+		if done {
+			return records, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+// record 将 v1 接口的记录转换为 types.MemosRecord
+func (m memosV1Memo) record() types.MemosRecord {
+	record := types.MemosRecord{
+		Content: stringPtr(m.Content),
+		Pinned:  &m.Pinned,
+	}
+	if m.Name != "" {
+		record.Name = stringPtr(m.Name)
+		if id, err := strconv.ParseInt(strings.TrimPrefix(m.Name, "memos/"), 10, 64); err == nil {
+			record.ID = &id
+		}
+	}
+
+	// v0.22 的 rowStatus 为 ACTIVE/ARCHIVED，之后的 state 为 NORMAL/ARCHIVED
+	status := m.State
+	if status == "" {
+		status = m.RowStatus
+	}
+	if status == "ACTIVE" || status == "STATE_UNSPECIFIED" || status == "" {
+		status = "NORMAL"
+	}
+	record.RowStatus = &status
+
+	created := m.CreateTime
+	if created == "" {
+		created = m.DisplayTime
+	}
+	if t, err := time.Parse(time.RFC3339Nano, created); err == nil {
+		ts := t.Unix()
+		record.CreatedTs = &ts
+		record.CreatedAt = stringPtr(created)
+	}
+	if t, err := time.Parse(time.RFC3339Nano, m.UpdateTime); err == nil {
+		ts := t.Unix()
+		record.UpdatedTs = &ts
+		record.UpdatedAt = stringPtr(m.UpdateTime)
+	}
+
+	for _, resource := range append(m.Resources, m.Attachments...) {
+		r := types.MemosResource{
+			Name:         stringPtr(resource.Name),
+			Filename:     stringPtr(resource.Filename),
+			ExternalLink: stringPtr(resource.ExternalLink),
+			Type:         stringPtr(resource.Type),
+			UID:          stringPtr(resource.UID),
+		}
+		if size, err := strconv.ParseInt(resource.Size, 10, 64); err == nil {
+			r.Size = &size
+		}
+		record.ResourceList = append(record.ResourceList, r)
+	}
+
+	return record
+}
+
+// stringPtr 返回字符串指针，空字符串返回 nil
+func stringPtr(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// DownloadResource 下载记录的附件，返回内容与 Content-Type
+// 外部链接直接下载且不携带令牌，服务器内部存储的附件按接口版本拼接下载地址
+func (c *MemosClient) DownloadResource(resource types.MemosResource) ([]byte, string, error) {
+	var req *resty.Request
+	var downloadURL string
+
+	filename := ""
+	if resource.Filename != nil {
+		filename = url.PathEscape(*resource.Filename)
+	}
+
+	switch {
+	case resource.ExternalLink != nil && *resource.ExternalLink != "":
+		req = c.external.R()
+		downloadURL = *resource.ExternalLink
+	case resource.Name != nil && strings.Contains(*resource.Name, "/"):
+		// v1 接口：resources/{id} 或 attachments/{id}
+		req = c.client.R()
+		downloadURL = fmt.Sprintf("%s/file/%s/%s", c.baseURL, *resource.Name, filename)
+	case resource.UID != nil && *resource.UID != "":
+		req = c.client.R()
+		downloadURL = fmt.Sprintf("%s/o/r/%s", c.baseURL, *resource.UID)
+	case resource.ID != nil:
+		req = c.client.R()
+		downloadURL = fmt.Sprintf("%s/o/r/%d/%s", c.baseURL, *resource.ID, filename)
+	default:
+		return nil, "", fmt.Errorf("附件缺少下载地址")
+	}
+
+	resp, err := req.SetHeader("Accept", "*/*").Get(downloadURL)
+	if err != nil {
+		return nil, "", fmt.Errorf("下载附件失败: %v", err)
+	}
+
+	if resp.StatusCode() != 200 {
+		return nil, "", fmt.Errorf("下载附件失败，状态码: %d", resp.StatusCode())
+	}
+
+	contentType := resp.Header().Get("Content-Type")
+	if resource.Type != nil && *resource.Type != "" {
+		contentType = *resource.Type
+	}
+	return resp.Body(), contentType, nil
+}
+
 // FetchMemos 获取Memos数据
 func (c *MemosClient) FetchMemos(limit, offset int, rowStatus string) ([]types.MemosRecord, error) {
 	resp, err := c.client.R().
@@ -0,0 +1,194 @@
+package exporter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"exporter-to-obsidian/internal/state"
+	"exporter-to-obsidian/internal/utils"
+)
+
+// imageExtensions Obsidian 可直接嵌入显示的图片扩展名
+var imageExtensions = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true,
+	".webp": true, ".svg": true, ".bmp": true, ".avif": true,
+}
+
+// preferredExtensions 常见 MIME 类型对应的扩展名，避免 mime 包返回 .jfif 等少见扩展名
+var preferredExtensions = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"image/svg+xml":   ".svg",
+	"application/pdf": ".pdf",
+	"text/plain":      ".txt",
+	"audio/mpeg":      ".mp3",
+	"video/mp4":       ".mp4",
+}
+
+// AttachmentStore 将附件下载到仓库中，按内容哈希命名以去重
+// 已下载的附件记录在状态文件中，文件仍存在时不会重复下载
+type AttachmentStore struct {
+	outputDir string
+	dir       string
+	source    string
+	state     *state.Store
+}
+
+// NewAttachmentStore 创建附件存储，dir 为附件目录，source 为状态文件中的数据源名称
+// 旧版本保存在状态目录 <source>-attachments.json 中的附件索引会被导入状态文件
+func NewAttachmentStore(outputDir, dir, source string) *AttachmentStore {
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(outputDir, dir)
+	}
+
+	store := &AttachmentStore{
+		outputDir: outputDir,
+		dir:       dir,
+		source:    source,
+		state:     state.Open(),
+	}
+	if content, err := os.ReadFile(filepath.Join(utils.GetStateDir(), source+"-attachments.json")); err == nil {
+		index := make(map[string]string)
+		if err := json.Unmarshal(content, &index); err == nil {
+			for key, rel := range index {
+				if _, ok := store.state.Entity(store.key(key)); !ok {
+					store.state.SetEntity(store.key(key), state.Entity{Path: rel})
+				}
+			}
+		}
+	}
+	return store
+}
+
+// key 附件在状态文件中的键
+func (s *AttachmentStore) key(key string) string {
+	return state.Key(s.source, "attachment", key)
+}
+
+// Store 保存附件并返回相对输出目录的路径
+// key 唯一标识附件，filename 为原始文件名，fetch 下载附件内容并返回 Content-Type
+func (s *AttachmentStore) Store(key, filename string, fetch func() ([]byte, string, error)) (string, error) {
+	if entity, ok := s.state.Entity(s.key(key)); ok {
+		if _, err := os.Stat(filepath.Join(s.outputDir, filepath.FromSlash(entity.Path))); err == nil {
+			return entity.Path, nil
+		}
+	}
+
+	content, contentType, err := fetch()
+	if err != nil {
+		return "", err
+	}
+
+	ext := strings.ToLower(filepath.Ext(filename))
+	if ext == "" && contentType != "" {
+		ext = extensionByType(contentType)
+	}
+
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+	path := filepath.Join(s.dir, hash[:16]+ext)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if _, err := utils.WriteFile(path, content, 0644); err != nil {
+			return "", fmt.Errorf("写入附件失败: %v", err)
+		}
+	}
+
+	rel := relativePath(s.outputDir, path)
+	s.state.SetEntity(s.key(key), state.Entity{Path: rel, Hash: hash})
+	return rel, nil
+}
+
+// Save 保存附件记录
+func (s *AttachmentStore) Save() error {
+	return s.state.Save()
+}
+
+// extensionByType 根据 Content-Type 推断扩展名
+func extensionByType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	if ext, ok := preferredExtensions[mediaType]; ok {
+		return ext
+	}
+	if exts, _ := mime.ExtensionsByType(mediaType); len(exts) > 0 {
+		return exts[0]
+	}
+	return ""
+}
+
+// isImage 根据 MIME 类型或扩展名判断附件是否为图片
+func isImage(contentType, path string) bool {
+	if strings.HasPrefix(contentType, "image/") {
+		return true
+	}
+	return imageExtensions[strings.ToLower(filepath.Ext(path))]
+}
+
+// AttachmentDownloader 下载滴答清单任务或笔记的附件，返回内容与 Content-Type
+type AttachmentDownloader interface {
+	DownloadAttachment(projectID, taskID, attachmentID, filename string) ([]byte, string, error)
+}
+
+// SetAttachmentDownloader 设置附件下载器，设置后附件会被下载到 DIDA365_ATTACHMENTS_DIR（默认 Attachments）下以任务ID命名的目录
+func (e *Dida365Exporter) SetAttachmentDownloader(downloader AttachmentDownloader) {
+	e.downloader = downloader
+}
+
+// downloadAttachment 下载附件并返回相对输出目录的路径
+// 附件以附件ID命名，扩展名优先根据 Content-Type 确定，已下载的附件不会重复下载
+func (e *Dida365Exporter) downloadAttachment(projectID, taskID, attachmentID, filename string) (string, error) {
+	dir := filepath.Join(e.attachmentsDir, taskID)
+	if path := findAttachment(dir, attachmentID); path != "" {
+		return relativePath(e.outputDir, path), nil
+	}
+
+	content, contentType, err := e.downloader.DownloadAttachment(projectID, taskID, attachmentID, filename)
+	if err != nil {
+		return "", err
+	}
+
+	ext := extensionByType(contentType)
+	if ext == "" || contentType == "application/octet-stream" {
+		ext = strings.ToLower(filepath.Ext(filename))
+	}
+
+	path := filepath.Join(dir, attachmentID+ext)
+	if _, err := utils.WriteFile(path, content, 0644); err != nil {
+		return "", fmt.Errorf("写入附件失败: %v", err)
+	}
+	return relativePath(e.outputDir, path), nil
+}
+
+// findAttachment 查找目录中已下载的附件，不存在时返回空字符串
+func findAttachment(dir, attachmentID string) string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() && strings.TrimSuffix(name, filepath.Ext(name)) == attachmentID {
+			return filepath.Join(dir, name)
+		}
+	}
+	return ""
+}
+
+// relativePath 返回相对输出目录、使用 / 分隔的路径，供 Obsidian 链接使用
+func relativePath(outputDir, path string) string {
+	rel, err := filepath.Rel(outputDir, path)
+	if err != nil {
+		rel = path
+	}
+	return filepath.ToSlash(rel)
+}
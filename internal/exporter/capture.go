@@ -0,0 +1,241 @@
+package exporter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"exporter-to-obsidian/internal/state"
+	"exporter-to-obsidian/internal/types"
+	"exporter-to-obsidian/internal/utils"
+)
+
+var (
+	// captureLinePattern 匹配未勾选的任务行，如 "- [ ] 写周报 📅 2024-01-01 ⏫ #工作"
+	captureLinePattern = regexp.MustCompile(`^(\s*[-*+]\s+\[ \]\s+)(.*)$`)
+	// captureDatePattern 匹配 Obsidian Tasks 的日期标记
+	captureDatePattern = regexp.MustCompile(`(📅|🛫|⏳)\s*(\d{4}-\d{2}-\d{2})`)
+	// captureTagPattern 匹配 #标签，支持 parent/child 形式的嵌套标签
+	captureTagPattern = regexp.MustCompile(`(^|\s)#([^\s#]+)`)
+)
+
+// capturePriorities Obsidian Tasks 优先级标记对应的滴答清单优先级，按优先级从高到低排列
+var capturePriorities = []struct {
+	mark     string
+	priority int
+}{
+	{"🔺", 5},
+	{"⏫", 5},
+	{"🔼", 3},
+	{"🔽", 1},
+	{"⏬", 0},
+}
+
+// CaptureItem 收集文件中待创建的任务
+type CaptureItem struct {
+	Line string // 原始行
+	Task types.Task
+}
+
+// CaptureFile 用于快速记录任务的收集文件
+type CaptureFile struct {
+	path      string
+	outputDir string
+	items     []CaptureItem
+	state     *state.Store // 已创建但尚未写回文件的任务
+}
+
+// LoadCaptureFile 读取 CAPTURE_PATH（默认 Inbox/Capture.md）中未勾选且尚未创建的任务
+// 上次已创建任务但未能写回的行，直接替换为任务链接，不再重复创建
+// 文件不存在时返回 nil
+func LoadCaptureFile(outputDir string) (*CaptureFile, error) {
+	path := utils.GetEnvOrDefault("CAPTURE_PATH", filepath.Join("Inbox", "Capture.md"))
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(outputDir, path)
+	}
+
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取收集文件失败: %v", err)
+	}
+
+	capture := &CaptureFile{path: path, outputDir: outputDir, state: state.Open()}
+	_, body, err := utils.ParseFrontMatter(string(content))
+	if err != nil {
+		body = string(content)
+	}
+	var pending []CaptureItem
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(strings.ReplaceAll(body, "\r\n", "\n"), "\n") {
+		task, ok := parseCaptureLine(line)
+		if !ok {
+			continue
+		}
+		item := CaptureItem{Line: line, Task: task}
+		// 相同的行依次创建并替换第一处，因此记录只对应第一处
+		if entity, ok := capture.state.Entity(captureKey(line)); ok && !seen[line] {
+			id := entity.Version
+			item.Task.ID = &id
+			pending = append(pending, item)
+		} else {
+			capture.items = append(capture.items, item)
+		}
+		seen[line] = true
+	}
+
+	for _, item := range pending {
+		fmt.Printf("任务 %s 已创建，替换收集文件中的对应行\n", derefString(item.Task.Title))
+		if err := capture.Resolve(item, item.Task); err != nil {
+			return nil, err
+		}
+	}
+	return capture, nil
+}
+
+// captureKey 已创建任务的收集行在状态文件中的键
+func captureKey(line string) string {
+	return state.Key(stateSource, "capture", state.Hash(line))
+}
+
+// Items 返回待创建的任务
+func (c *CaptureFile) Items() []CaptureItem {
+	return c.items
+}
+
+// Resolve 记录任务已创建，并立即将原始行替换为指向任务的链接
+// 替换前先在状态文件中记录任务ID，写回失败或进程中断时，下次读取会直接使用已创建的任务
+func (c *CaptureFile) Resolve(item CaptureItem, task types.Task) error {
+	key := captureKey(item.Line)
+	c.state.SetEntity(key, state.Entity{Version: derefString(task.ID), Path: relativePath(c.outputDir, c.path)})
+	if err := c.state.Save(); err != nil {
+		return err
+	}
+
+	indent := item.Line[:len(item.Line)-len(strings.TrimLeft(item.Line, " \t"))]
+	replaced, err := c.replaceLine(item.Line, indent+formatIndexLine(task))
+	if err != nil {
+		return err
+	}
+	if !replaced {
+		// 原始行已在 Obsidian 中被修改，保留记录，恢复原样时仍能替换
+		fmt.Printf("收集文件中未找到任务 %s 的原始行，未替换为链接\n", derefString(task.Title))
+		return nil
+	}
+
+	c.state.DeleteEntity(key)
+	return c.state.Save()
+}
+
+// replaceLine 重新读取文件并替换第一处与原始行相同的行，避免覆盖读取之后在 Obsidian 中的编辑
+func (c *CaptureFile) replaceLine(original, replacement string) (bool, error) {
+	content, err := os.ReadFile(c.path)
+	if err != nil {
+		return false, fmt.Errorf("读取收集文件失败: %v", err)
+	}
+
+	lines := strings.Split(string(content), "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimSuffix(line, "\r")
+		if trimmed != original {
+			continue
+		}
+		lines[i] = replacement + line[len(trimmed):]
+		if _, err := utils.WriteFile(c.path, []byte(strings.Join(lines, "\n")), 0644); err != nil {
+			return false, fmt.Errorf("写入收集文件失败: %v", err)
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// parseCaptureLine 解析收集文件中的一行，返回待创建的任务
+// 已勾选、已链接到任务或没有标题的行会被忽略
+func parseCaptureLine(line string) (types.Task, bool) {
+	matches := captureLinePattern.FindStringSubmatch(line)
+	if matches == nil {
+		return types.Task{}, false
+	}
+	text := matches[2]
+	if strings.HasPrefix(strings.TrimSpace(text), "[[") {
+		return types.Task{}, false
+	}
+
+	kind := "TEXT"
+	task := types.Task{Kind: &kind}
+
+	// 日期
+	var startDate, dueDate *time.Time
+	for _, m := range captureDatePattern.FindAllStringSubmatch(text, -1) {
+		date, err := time.ParseInLocation("2006-01-02", m[2], utils.Location())
+		if err != nil {
+			continue
+		}
+		if m[1] == "📅" {
+			dueDate = &date
+		} else {
+			startDate = &date
+		}
+	}
+	text = captureDatePattern.ReplaceAllString(text, "")
+
+	// 优先级，有多个标记时取最高的一个
+	for _, p := range capturePriorities {
+		if !strings.Contains(text, p.mark) {
+			continue
+		}
+		if task.Priority == nil {
+			priority := p.priority
+			task.Priority = &priority
+		}
+		text = strings.ReplaceAll(text, p.mark, "")
+	}
+
+	// 标签，嵌套标签只取最后一级作为滴答清单标签名称
+	for _, m := range captureTagPattern.FindAllStringSubmatch(text, -1) {
+		segments := strings.Split(m[2], "/")
+		task.Tags = append(task.Tags, strings.ToLower(segments[len(segments)-1]))
+	}
+	text = captureTagPattern.ReplaceAllString(text, "$1")
+
+	title := strings.Join(strings.Fields(text), " ")
+	if title == "" {
+		return types.Task{}, false
+	}
+	task.Title = &title
+
+	setCaptureDates(&task, startDate, dueDate)
+	return task, true
+}
+
+// setCaptureDates 按滴答清单全天任务的格式设置开始与截止日期
+// 跨天的全天任务截止日期为最后一天的次日零点
+func setCaptureDates(task *types.Task, startDate, dueDate *time.Time) {
+	if startDate == nil && dueDate == nil {
+		return
+	}
+	if startDate == nil {
+		startDate = dueDate
+	}
+
+	format := func(t time.Time) *string {
+		s := t.UTC().Format("2006-01-02T15:04:05.000+0000")
+		return &s
+	}
+
+	allDay := true
+	task.IsAllDay = &allDay
+	task.StartDate = format(*startDate)
+	if dueDate != nil && dueDate.After(*startDate) {
+		task.DueDate = format(dueDate.AddDate(0, 0, 1))
+	} else {
+		task.DueDate = format(*startDate)
+	}
+	zone := utils.Location().String()
+	task.TimeZone = &zone
+}
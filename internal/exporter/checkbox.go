@@ -0,0 +1,314 @@
+package exporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"exporter-to-obsidian/internal/state"
+	"exporter-to-obsidian/internal/types"
+	"exporter-to-obsidian/internal/utils"
+)
+
+// 本地与滴答清单同时修改时的处理策略
+const (
+	conflictLatest = "latest" // 比较文件修改时间与任务修改时间，较新的一方生效
+	conflictRemote = "remote" // 任务在上次导出后被修改过时，以滴答清单为准
+	conflictLocal  = "local"  // 始终以 Obsidian 中的勾选为准
+)
+
+// checkboxLinePattern 匹配引用任务的复选框行，如 "- [x] [[id|标题]]" 或 "1. [ ] [[id|标题]]"
+var checkboxLinePattern = regexp.MustCompile(`^\s*(?:[-*+]|\d+[.)])\s+\[([ xX])\]\s+\[\[([^|\]#]+)`)
+
+// CheckboxChange 在 Obsidian 中修改的任务完成状态
+type CheckboxChange struct {
+	Task      types.Task
+	Completed bool
+}
+
+// checkboxCheckpoint 状态文件中记录上次导出时间（毫秒）的检查点
+const checkboxCheckpoint = stateSource + "/checkbox"
+
+// checkboxEntry 旧版本复选框状态文件中的任务记录
+type checkboxEntry struct {
+	Checked      bool   `json:"checked"`
+	ModifiedTime string `json:"modifiedTime,omitempty"`
+}
+
+// checkboxState 旧版本复选框状态文件的内容
+type checkboxState struct {
+	ExportedAt time.Time                `json:"exportedAt"`
+	Tasks      map[string]checkboxEntry `json:"tasks"`
+}
+
+// localCheckbox 文件中读取到的复选框状态
+type localCheckbox struct {
+	checked bool
+	modTime time.Time
+	path    string
+}
+
+// checkboxStatePath 旧版本的复选框状态文件路径，内容导入状态文件后删除
+func checkboxStatePath() string {
+	return filepath.Join(utils.GetStateDir(), "checkboxes.json")
+}
+
+// loadCheckboxState 读取旧版本的复选框状态，不存在时返回 nil
+func loadCheckboxState(path string) (*checkboxState, error) {
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取复选框状态失败: %v", err)
+	}
+
+	state := &checkboxState{}
+	if err := json.Unmarshal(content, state); err != nil {
+		return nil, fmt.Errorf("解析复选框状态失败: %v", err)
+	}
+	return state, nil
+}
+
+// importCheckboxState 将旧版本的复选框状态导入状态文件中的任务记录，保存状态后删除旧文件
+func (e *Dida365Exporter) importCheckboxState() {
+	path := checkboxStatePath()
+	legacy, err := loadCheckboxState(path)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+	if legacy == nil {
+		return
+	}
+	e.legacyFiles = append(e.legacyFiles, path)
+	if e.state.Checkpoint(checkboxCheckpoint).Value != 0 {
+		return
+	}
+
+	for id, entry := range legacy.Tasks {
+		key := state.Key(stateSource, kindTask, id)
+		entity, ok := e.state.Entity(key)
+		if !ok {
+			continue
+		}
+		if entry.Checked {
+			entity.Status = 2
+		} else if entity.Status == 2 {
+			entity.Status = 0
+		}
+		entity.Modified = entry.ModifiedTime
+		e.state.SetEntity(key, entity)
+	}
+	e.state.SetCheckpoint(checkboxCheckpoint, state.Checkpoint{Value: legacy.ExportedAt.UnixMilli()})
+}
+
+// parseCheckboxes 解析内容中引用任务的复选框，返回任务ID到勾选状态的映射
+func parseCheckboxes(content string) map[string]bool {
+	checkboxes := make(map[string]bool)
+	for _, line := range strings.Split(content, "\n") {
+		matches := checkboxLinePattern.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+		checkboxes[strings.TrimSpace(matches[2])] = matches[1] != " "
+	}
+	return checkboxes
+}
+
+// scanCheckboxes 读取项目索引以及上次导出后修改过的每日摘要中的复选框
+// 写入用户每日笔记时，只读取最近一个月笔记中滴答清单区域内的复选框
+// 同一任务出现在多个文件中时，以最近修改的文件为准
+func (e *Dida365Exporter) scanCheckboxes(since time.Time) map[string]localCheckbox {
+	paths := []string{e.tasksInboxPath}
+	managed := make(map[string]bool)
+	if mergeDailyNote() {
+		today := utils.Now()
+		for i := 0; i <= 31; i++ {
+			path := e.dailyNote.Path(today.AddDate(0, 0, -i))
+			if info, err := os.Stat(path); err == nil && info.ModTime().After(since) && !managed[path] {
+				managed[path] = true
+				paths = append(paths, path)
+			}
+		}
+	}
+	entries, _ := os.ReadDir(e.dailyDir)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), "-Dida365.md") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !info.ModTime().After(since) {
+			continue
+		}
+		paths = append(paths, filepath.Join(e.dailyDir, entry.Name()))
+	}
+
+	type file struct {
+		path    string
+		modTime time.Time
+	}
+	var files []file
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		files = append(files, file{path: path, modTime: info.ModTime()})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.Before(files[j].modTime)
+	})
+
+	checkboxes := make(map[string]localCheckbox)
+	for _, f := range files {
+		content, err := os.ReadFile(f.path)
+		if err != nil {
+			continue
+		}
+		text := string(content)
+		if managed[f.path] {
+			section, ok := managedSection(text, sectionDida365)
+			if !ok {
+				continue
+			}
+			text = section
+		}
+		for id, checked := range parseCheckboxes(text) {
+			checkboxes[id] = localCheckbox{checked: checked, modTime: f.modTime, path: f.path}
+		}
+	}
+	return checkboxes
+}
+
+// PendingCheckboxChanges 对比上次导出的状态，找出在 Obsidian 中勾选或取消勾选的任务
+// 重复任务不会同步，需要在滴答清单中完成
+// 滴答清单中的任务在上次导出后也被修改时，按 CHECKBOX_CONFLICT_POLICY 决定是否采用本地修改
+func (e *Dida365Exporter) PendingCheckboxChanges() []CheckboxChange {
+	checkpoint := e.state.Checkpoint(checkboxCheckpoint)
+	if checkpoint.Value == 0 {
+		// 首次运行没有可对比的状态
+		return nil
+	}
+
+	remote := make(map[string]types.Task)
+	for _, task := range append(append([]types.Task{}, e.todoTasks...), e.completedTasks...) {
+		if task.ID != nil {
+			remote[*task.ID] = task
+		}
+	}
+
+	policy := utils.GetEnvOrDefault("CHECKBOX_CONFLICT_POLICY", conflictLatest)
+
+	var changes []CheckboxChange
+	for id, local := range e.scanCheckboxes(time.UnixMilli(checkpoint.Value)) {
+		entity, ok := e.state.Entity(state.Key(stateSource, kindTask, id))
+		if !ok || (entity.Status == 2) == local.checked {
+			continue
+		}
+
+		task, ok := remote[id]
+		if !ok {
+			fmt.Printf("任务 %s 不在本次同步的数据中，忽略本地勾选\n", id)
+			continue
+		}
+		if (derefInt(task.Status) == 2) == local.checked {
+			// 两端已经一致
+			continue
+		}
+		if derefString(task.RepeatFlag) != "" {
+			// 直接修改状态会结束整个重复序列，而不是推进到下一次重复
+			fmt.Printf("任务 %s 是重复任务，忽略 %s 中的勾选，请在滴答清单中完成\n", id, filepath.Base(local.path))
+			continue
+		}
+
+		if derefString(task.ModifiedTime) != entity.Modified && !preferLocal(policy, task, local) {
+			fmt.Printf("任务 %s 在滴答清单中也被修改，忽略 %s 中的勾选（策略：%s）\n", id, filepath.Base(local.path), policy)
+			continue
+		}
+
+		changes = append(changes, CheckboxChange{Task: task, Completed: local.checked})
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return derefString(changes[i].Task.ID) < derefString(changes[j].Task.ID)
+	})
+	return changes
+}
+
+// preferLocal 两端都有修改时判断是否采用本地的勾选
+func preferLocal(policy string, task types.Task, local localCheckbox) bool {
+	switch policy {
+	case conflictLocal:
+		return true
+	case conflictRemote:
+		return false
+	default:
+		modified := utils.ParseDateTime(derefString(task.ModifiedTime))
+		return modified == nil || local.modTime.After(*modified)
+	}
+}
+
+// ApplyCheckboxChanges 将已同步到滴答清单的状态变更应用到待导出的任务中
+func (e *Dida365Exporter) ApplyCheckboxChanges(changes []CheckboxChange) {
+	if len(changes) == 0 {
+		return
+	}
+
+	now := time.Now().UTC().Format("2006-01-02T15:04:05.000+0000")
+	completed := make(map[string]bool)
+	for _, change := range changes {
+		completed[derefString(change.Task.ID)] = change.Completed
+	}
+
+	var todoTasks, completedTasks []types.Task
+	for _, task := range append(append([]types.Task{}, e.todoTasks...), e.completedTasks...) {
+		done, changed := completed[derefString(task.ID)]
+		if !changed {
+			done = derefInt(task.Status) == 2
+		} else if done {
+			status := 2
+			task.Status = &status
+			task.CompletedTime = &now
+			task.ModifiedTime = &now
+		} else {
+			status := 0
+			task.Status = &status
+			task.CompletedTime = nil
+			task.ModifiedTime = &now
+		}
+
+		if done {
+			completedTasks = append(completedTasks, task)
+		} else {
+			todoTasks = append(todoTasks, task)
+		}
+	}
+	e.todoTasks = todoTasks
+	e.completedTasks = completedTasks
+}
+
+// SaveCheckboxState 在状态文件的任务记录中保存本次导出的完成状态与修改时间，供下次导出时对比
+func (e *Dida365Exporter) SaveCheckboxState() error {
+	for _, task := range append(append([]types.Task{}, e.todoTasks...), e.completedTasks...) {
+		if task.ID == nil {
+			continue
+		}
+		// 只有导出了文件的任务才会出现在项目索引与摘要中
+		key := state.Key(stateSource, kindTask, *task.ID)
+		entity, ok := e.state.Entity(key)
+		if !ok {
+			continue
+		}
+		entity.Status = derefInt(task.Status)
+		entity.Modified = derefString(task.ModifiedTime)
+		e.state.SetEntity(key, entity)
+	}
+	e.state.SetCheckpoint(checkboxCheckpoint, state.Checkpoint{Value: time.Now().UnixMilli()})
+	return nil
+}
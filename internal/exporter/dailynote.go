@@ -0,0 +1,183 @@
+package exporter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"exporter-to-obsidian/internal/utils"
+)
+
+// 每日摘要的写入方式
+const (
+	dailyModeSeparate = "separate" // 只生成独立的摘要文件（默认）
+	dailyModeMerge    = "merge"    // 只写入用户的每日笔记
+	dailyModeBoth     = "both"     // 两者都写入
+)
+
+// 每日笔记中受管理区域的名称
+const (
+	sectionDida365 = "dida365"
+	sectionMemos   = "memos"
+)
+
+// dailyNoteDatePattern 匹配 Obsidian 模板中的 {{date}}、{{date:YYYY-MM-DD}} 与 {{title}}
+var dailyNoteDatePattern = regexp.MustCompile(`\{\{\s*(date|title)(?::([^}]*))?\s*\}\}`)
+
+// DailyNote 用户自己的每日笔记，导出内容写入其中由标记注释包围的区域
+type DailyNote struct {
+	outputDir string
+	pattern   string
+	template  string
+}
+
+// NewDailyNote 创建每日笔记写入器
+// DAILY_NOTE_PATTERN 为相对输出目录的路径格式（默认 YYYY-MM-DD.md），DAILY_NOTE_TEMPLATE 为笔记不存在时使用的模板
+func NewDailyNote(outputDir string) *DailyNote {
+	template := os.Getenv("DAILY_NOTE_TEMPLATE")
+	if template != "" && !filepath.IsAbs(template) {
+		template = filepath.Join(outputDir, template)
+	}
+	return &DailyNote{
+		outputDir: outputDir,
+		pattern:   utils.GetEnvOrDefault("DAILY_NOTE_PATTERN", "YYYY-MM-DD.md"),
+		template:  template,
+	}
+}
+
+// dailyNoteMode 读取 DAILY_NOTE_MODE，未知的值按 separate 处理
+func dailyNoteMode() string {
+	switch mode := strings.ToLower(os.Getenv("DAILY_NOTE_MODE")); mode {
+	case dailyModeMerge, dailyModeBoth:
+		return mode
+	default:
+		return dailyModeSeparate
+	}
+}
+
+// writeSeparateDaily 是否生成独立的每日摘要文件
+func writeSeparateDaily() bool {
+	return dailyNoteMode() != dailyModeMerge
+}
+
+// mergeDailyNote 是否写入用户的每日笔记
+func mergeDailyNote() bool {
+	return dailyNoteMode() != dailyModeSeparate
+}
+
+// Path 返回指定日期的每日笔记路径
+func (n *DailyNote) Path(date time.Time) string {
+	path := formatDatePattern(n.pattern, date)
+	if !strings.HasSuffix(path, ".md") {
+		path += ".md"
+	}
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(n.outputDir, filepath.FromSlash(path))
+}
+
+// Merge 将渲染好的摘要写入每日笔记中的受管理区域，区域外的内容保持不变
+// 笔记不存在时按模板创建，没有对应区域时追加到笔记末尾
+func (n *DailyNote) Merge(date time.Time, section, rendered string) error {
+	path := n.Path(date)
+
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		content, err = n.render(date, path)
+	}
+	if err != nil {
+		return fmt.Errorf("读取每日笔记失败: %v", err)
+	}
+
+	// 摘要自身的 Front Matter 只用于独立文件，不写入用户的笔记
+	body := rendered
+	if _, b, err := utils.ParseFrontMatter(rendered); err == nil {
+		body = b
+	}
+
+	merged := replaceManagedSection(string(content), section, strings.TrimSpace(body))
+	if merged == string(content) {
+		return nil
+	}
+
+	if _, err := utils.WriteFile(path, []byte(merged), 0644); err != nil {
+		return fmt.Errorf("写入每日笔记失败: %v", err)
+	}
+
+	fmt.Printf("已更新每日笔记：%s\n", filepath.Base(path))
+	return nil
+}
+
+// render 按 DAILY_NOTE_TEMPLATE 生成新笔记的内容，未配置模板时返回空内容
+func (n *DailyNote) render(date time.Time, path string) ([]byte, error) {
+	if n.template == "" {
+		return nil, nil
+	}
+	content, err := os.ReadFile(n.template)
+	if err != nil {
+		return nil, fmt.Errorf("读取每日笔记模板失败: %v", err)
+	}
+
+	title := strings.TrimSuffix(filepath.Base(path), ".md")
+	return []byte(dailyNoteDatePattern.ReplaceAllStringFunc(string(content), func(match string) string {
+		parts := dailyNoteDatePattern.FindStringSubmatch(match)
+		if parts[1] == "title" {
+			return title
+		}
+		format := strings.TrimSpace(parts[2])
+		if format == "" {
+			format = "YYYY-MM-DD"
+		}
+		return formatDatePattern(format, date)
+	})), nil
+}
+
+// managedSection 返回内容中指定受管理区域的内容，没有该区域时返回 false
+func managedSection(content, section string) (string, bool) {
+	start, end := sectionMarkers(section)
+	i := strings.Index(content, start)
+	if i < 0 {
+		return "", false
+	}
+	j := strings.Index(content[i:], end)
+	if j < 0 {
+		return "", false
+	}
+	return content[i+len(start) : i+j], true
+}
+
+// replaceManagedSection 替换受管理区域的内容，不存在时追加到末尾
+func replaceManagedSection(content, section, body string) string {
+	start, end := sectionMarkers(section)
+	block := start + "\n" + body + "\n" + end
+
+	if i := strings.Index(content, start); i >= 0 {
+		if j := strings.Index(content[i:], end); j >= 0 {
+			return content[:i] + block + content[i+j+len(end):]
+		}
+	}
+
+	if strings.TrimSpace(content) == "" {
+		return block + "\n"
+	}
+	return strings.TrimRight(content, "\n") + "\n\n" + block + "\n"
+}
+
+// sectionMarkers 受管理区域的起止标记注释
+func sectionMarkers(section string) (string, string) {
+	return fmt.Sprintf("<!-- %s:start -->", section), fmt.Sprintf("<!-- %s:end -->", section)
+}
+
+// formatDatePattern 将 YYYY、MM、DD 等日期标记替换为指定日期的值
+func formatDatePattern(pattern string, date time.Time) string {
+	return strings.NewReplacer(
+		"YYYY", date.Format("2006"),
+		"YY", date.Format("06"),
+		"MM", date.Format("01"),
+		"DD", date.Format("02"),
+	).Replace(pattern)
+}
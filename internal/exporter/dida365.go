@@ -5,9 +5,11 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"time"
 	"regexp"
 
+	"exporter-to-obsidian/internal/state"
 	"exporter-to-obsidian/internal/types"
 	"exporter-to-obsidian/internal/utils"
 )
@@ -30,8 +32,24 @@ type Dida365Exporter struct {
 	tasksInboxPath string
 	notesDir       string
 	columnsDir     string
+	tagsDir        string
+	habitsDir      string
+	attachmentsDir string
+	tags           map[string]types.Tag // 标签名称到标签的映射
+	downloader     AttachmentDownloader
+	taskChecker    TaskChecker
+	habits         []types.Habit
+	checkins       *types.HabitCheckinsResponse
+	dailyNote      *DailyNote
+	owned          map[string]string // 本次导出拥有的文件，相对路径到对象记录的键
+	renderer       *Renderer
+	state          *state.Store // 导出文件与复选框状态的记录
+	legacyFiles    []string     // 已导入状态文件的旧版本状态文件，保存状态后删除
 }
 
+// stateSource 状态文件中滴答清单的数据源名称
+const stateSource = "dida365"
+
 // NewDida365Exporter 创建新的滴答清单导出器
 func NewDida365Exporter(projects []types.Project, todoTasks, completedTasks []types.Task, outputDir string, note_projects []types.Project, notes []types.Task, all_columns []types.Column) *Dida365Exporter {
 	if outputDir == "" {
@@ -47,6 +65,8 @@ func NewDida365Exporter(projects []types.Project, todoTasks, completedTasks []ty
 	tasksInboxDir := filepath.Join(outputDir, utils.GetEnvOrDefault("TASKS_INBOX_PATH", "Inbox"))
 	notesDir := filepath.Join(outputDir, utils.GetEnvOrDefault("NOTES_DIR", "Notes"))
 	columnsDir := filepath.Join(outputDir, utils.GetEnvOrDefault("COLUMNS_DIR", "Columns"))
+	tagsDir := filepath.Join(outputDir, utils.GetEnvOrDefault("TAGS_DIR", "Tags"))
+	attachmentsDir := filepath.Join(outputDir, utils.GetEnvOrDefault("DIDA365_ATTACHMENTS_DIR", "Attachments"))
 
 	exporter := &Dida365Exporter{
 		projects:       projects,
@@ -65,6 +85,14 @@ func NewDida365Exporter(projects []types.Project, todoTasks, completedTasks []ty
 		tasksInboxPath: filepath.Join(tasksInboxDir, "TasksInbox.md"),
 		notesDir:       notesDir,
 		columnsDir:     columnsDir,
+		tagsDir:        tagsDir,
+		habitsDir:      filepath.Join(outputDir, utils.GetEnvOrDefault("HABITS_DIR", "Habits")),
+		attachmentsDir: attachmentsDir,
+		tags:           make(map[string]types.Tag),
+		owned:          make(map[string]string),
+		renderer:       NewRenderer(outputDir),
+		state:          state.Open(),
+		dailyNote:      NewDailyNote(outputDir),
 	}
 
 	// 确保所有目录存在
@@ -77,6 +105,7 @@ func NewDida365Exporter(projects []types.Project, todoTasks, completedTasks []ty
 		exporter.tasksInboxDir,
 		exporter.notesDir,
 		exporter.columnsDir,
+		exporter.tagsDir,
 	}
 
 	for _, dir := range dirs {
@@ -85,6 +114,10 @@ func NewDida365Exporter(projects []types.Project, todoTasks, completedTasks []ty
 		}
 	}
 
+	// 导入旧版本的文件清单与复选框状态
+	exporter.importManifest()
+	exporter.importCheckboxState()
+
 	return exporter
 }
 
@@ -106,31 +139,33 @@ func (e *Dida365Exporter) createNoteMarkdown(task types.Task) error {
 
 	filename := fmt.Sprintf("%s.md", *task.ID)
 	filepath := filepath.Join(e.notesDir, filename)
+	e.track(kindNote, *task.ID, derefString(task.ProjectID), derefInt(task.Status), filepath)
 
 	// 检查文件是否需要更新
-	if e.shouldSkipFile(filepath, task) {
+	if e.shouldSkipFile(kindNote, filepath, task) {
 		// fmt.Printf("笔记文件已是最新: %s\n", filename)
 		return nil
 	}
 
-	// 准备Front Matter
-	content := e.buildTaskFrontMatter(task)
-
-	// 添加任务描述
+	data := TaskNoteData{
+		Task:        task,
+		FrontMatter: e.buildTaskFrontMatter(task),
+	}
 	if task.Content != nil && *task.Content != "" {
-		convertedContent := e.convertImageURLs(*task.Content, *task.ProjectID, *task.ID)
-		content += fmt.Sprintf("%s\n\n", convertedContent)
+		data.Content = e.convertImageURLs(*task.Content, *task.ProjectID, *task.ID)
 	}
 
-	// 删除旧文件并写入新文件
-	if _, err := os.Stat(filepath); err == nil {
-		os.Remove(filepath)
-		// fmt.Printf("删除旧文件: %s\n", filename)
+	content, err := e.renderer.Render(tmplNote, data)
+	if err != nil {
+		return err
 	}
 
-	if err := os.WriteFile(filepath, []byte(content), 0644); err != nil {
+	// 保留分界标记之后用户添加的内容
+	content = withUserSection(content, filepath)
+	if _, err := utils.WriteFile(filepath, []byte(content), 0644); err != nil {
 		return fmt.Errorf("写入笔记文件失败: %v", err)
 	}
+	e.recordFile(kindNote, filepath, task, content)
 	return nil
 }
 
@@ -152,53 +187,61 @@ func (e *Dida365Exporter) createColumnMarkdown(column types.Column) error {
 
 	filename := fmt.Sprintf("%s.md", *column.ID)
 	filepath := filepath.Join(e.columnsDir, filename)
+	e.track(kindColumn, *column.ID, derefString(column.ProjectID), 0, filepath)
 
-	if e.skip(filepath) {
-		// fmt.Printf("文件已存在: %s\n", filename)
-		return nil
-	}
-
-	frontMatter := ""
-	write := func(k string, v interface{}) {
-		if v != nil {
-			frontMatter += fmt.Sprintf("%s: %v\n", k, v)
+	var project *types.Project
+	for i := range e.projects {
+		if derefString(column.ProjectID) == e.projects[i].ID {
+			project = &e.projects[i]
+			break
 		}
 	}
-	write("title", *column.Name)
-	write("column_id", *column.ID)
-	write("project_id", *column.ProjectID)
-	write("created_time", utils.FormatTime(*column.CreatedTime, "2006-01-02 15:04:05"))
-	write("modified_time", utils.FormatTime(*column.ModifiedTime, "2006-01-02 15:04:05"))
-	content := utils.GetFrontMatter([]string{"fullwidth", "noyaml"}, frontMatter)
 
-	for _, project := range e.projects {
-		if *column.ProjectID == project.ID {
-			content += fmt.Sprintf("# %s —— %s\n\n", project.Name, *column.Name)
-			break
-		}
+	// 分组与所属项目未变化且文件未被修改时跳过，旧版本生成的文件没有导出记录，会被重新生成
+	key := state.Key(stateSource, kindColumn, *column.ID)
+	version := columnVersion(column, project)
+	if e.isUpToDate(key, version, filepath) {
+		return nil
 	}
 
-	content += "```dataviewjs\n" +
-		"dv.view('dida365TaskTable', {\n" +
-		"    folderPath: '9.Archive/Dida365/Tasks',\n" +
-		"    condition: (p, c) => {\n" +
-		"        return p.frontmatter?.column_id === c.frontmatter?.column_id;\n" +
-		"    }\n" +
-		"});\n" +
-		"```\n\n"
+	frontMatter := utils.NewFrontMatter()
+	frontMatter.Set("title", derefString(column.Name))
+	frontMatter.Set("column_id", derefString(column.ID))
+	frontMatter.Set("project_id", derefString(column.ProjectID))
+	frontMatter.Set("created_time", utils.FormatTime(derefString(column.CreatedTime), "2006-01-02 15:04:05"))
+	frontMatter.Set("modified_time", utils.FormatTime(derefString(column.ModifiedTime), "2006-01-02 15:04:05"))
+	frontMatter.SetDisplay("fullwidth", "noyaml")
+	data := ColumnNoteData{
+		Column:      column,
+		FrontMatter: frontMatter.String(),
+		Project:     project,
+	}
 
-	if _, err := os.Stat(filepath); err == nil {
-		os.Remove(filepath)
-		// fmt.Printf("删除旧文件: %s\n", filename)
+	content, err := e.renderer.Render(tmplColumn, data)
+	if err != nil {
+		return err
 	}
 
-	if err := os.WriteFile(filepath, []byte(content), 0644); err != nil {
+	if _, err := utils.WriteFile(filepath, []byte(content), 0644); err != nil {
 		return fmt.Errorf("写入分组文件失败: %v", err)
 	}
+	e.recordEntity(key, version, filepath, content)
 
 	return nil
 }
 
+// columnVersion 分组的版本，由修改时间与所属项目名称组成，没有修改时间时返回空字符串
+func columnVersion(column types.Column, project *types.Project) string {
+	if column.ModifiedTime == nil {
+		return ""
+	}
+	version := *column.ModifiedTime
+	if project != nil {
+		version += "#" + project.Name
+	}
+	return version
+}
+
 // ExportProjectTasks 导出所有项目的任务
 func (e *Dida365Exporter) ExportProjectTasks() error {
 	// 构建任务映射
@@ -211,7 +254,9 @@ func (e *Dida365Exporter) ExportProjectTasks() error {
 	}
 
 	// 创建项目索引内容
-	allContent := utils.GetFrontMatter([]string{"noyaml"}, "")
+	index := TasksInboxData{
+		FrontMatter: displayFrontMatter("noyaml"),
+	}
 
 	// 为每个项目生成内容
 	for _, project := range e.projects {
@@ -222,7 +267,7 @@ func (e *Dida365Exporter) ExportProjectTasks() error {
 				fmt.Printf("创建任务文件失败: %v\n", err)
 			}
 		}
-		allContent += e.getProjectIndexContent(project, projectTasks)
+		index.Projects = append(index.Projects, e.getProjectIndex(project, projectTasks))
 	}
 
 	// 为已完成任务创建Markdown文件
@@ -232,12 +277,20 @@ func (e *Dida365Exporter) ExportProjectTasks() error {
 		}
 	}
 
+	allContent, err := e.renderer.Render(tmplTasksInbox, index)
+	if err != nil {
+		return err
+	}
+
 	// 写入项目索引文件
-	if err := os.WriteFile(e.tasksInboxPath, []byte(allContent), 0644); err != nil {
+	written, err := utils.WriteFile(e.tasksInboxPath, []byte(allContent), 0644)
+	if err != nil {
 		return fmt.Errorf("写入项目索引文件失败: %v", err)
 	}
 
-	fmt.Println("已创建统一项目索引文件: TasksInbox.md")
+	if written {
+		fmt.Println("已创建统一项目索引文件: TasksInbox.md")
+	}
 	return nil
 }
 
@@ -251,27 +304,41 @@ func (e *Dida365Exporter) getProjectTasks(projectID string, tasks []types.Task)
 	}
 	return projectTasks
 }
+// convertImageURLs 转换内容中的附件引用
+// 设置了附件下载器时下载到仓库并改为本地嵌入，否则改为滴答清单的附件地址
 func (e *Dida365Exporter) convertImageURLs(content, projectID, taskID string) string {
-	// 正则匹配图片格式：![image](<attachment_id>/<filename>)
-	re := regexp.MustCompile(`!\[image]\(([0-9a-f]+)/([^\)]+)\)`)
-	
-	// 替换为指定URL格式
+	// 正则匹配附件格式：![image](<attachment_id>/<filename>) 或 ![file](<attachment_id>/<filename>)
+	re := regexp.MustCompile(`!\[([^\]]*)]\(([0-9a-f]+)/([^\)]+)\)`)
+
+	// 替换为本地嵌入或指定URL格式
 	content = re.ReplaceAllStringFunc(content, func(match string) string {
 		parts := re.FindStringSubmatch(match)
-		if len(parts) < 3 {
+		if len(parts) < 4 {
 			return match // 不符合格式则返回原字符串
 		}
-		
-		attachmentID := parts[1]
-		newURL := fmt.Sprintf("https://dida365.com/api/v1/attachment/%s/%s/%s.jpg", 
-			projectID, taskID, attachmentID)
-		
-		return fmt.Sprintf("![image](%s)", newURL)
+
+		attachmentID := parts[2]
+		if e.downloader != nil {
+			path, err := e.downloadAttachment(projectID, taskID, attachmentID, parts[3])
+			if err == nil {
+				return fmt.Sprintf("![[%s]]", path)
+			}
+			fmt.Printf("下载附件失败 %s/%s: %v\n", taskID, attachmentID, err)
+		}
+
+		ext := strings.ToLower(filepath.Ext(parts[3]))
+		if ext == "" {
+			ext = ".jpg"
+		}
+		newURL := fmt.Sprintf("https://dida365.com/api/v1/attachment/%s/%s/%s%s",
+			projectID, taskID, attachmentID, ext)
+
+		return fmt.Sprintf("![%s](%s)", parts[1], newURL)
 	})
 
 	// 转换任务链接格式
 	content = e.convertTaskLinks(content)
-	
+
 	return content
 }
 
@@ -303,95 +370,72 @@ func (e *Dida365Exporter) createTaskMarkdown(task types.Task, taskMap map[string
 
 	filename := fmt.Sprintf("%s.md", *task.ID)
 	filepath := filepath.Join(e.tasksDir, filename)
+	e.track(kindTask, *task.ID, derefString(task.ProjectID), derefInt(task.Status), filepath)
 
 	// 检查文件是否需要更新
-	if e.shouldSkipFile(filepath, task) {
+	if e.shouldSkipFile(kindTask, filepath, task) {
 		// fmt.Printf("任务文件已是最新: %s\n", filename)
 		return nil
 	}
 
-	// 准备Front Matter
-	content := e.buildTaskFrontMatter(task)
-
-	// 添加任务描述
+	data := TaskNoteData{
+		Task:        task,
+		FrontMatter: e.buildTaskFrontMatter(task),
+	}
 	if task.Content != nil && *task.Content != "" {
-		convertedContent := e.convertImageURLs(*task.Content, *task.ProjectID, *task.ID)
-		content += fmt.Sprintf("%s\n\n", convertedContent)
+		data.Content = e.convertImageURLs(*task.Content, *task.ProjectID, *task.ID)
 	}
-
 	if task.Desc != nil && *task.Desc != "" {
-		convertedContent := e.convertImageURLs(*task.Desc, *task.ProjectID, *task.ID)
-		content += fmt.Sprintf("%s\n\n", convertedContent)
+		data.Desc = e.convertImageURLs(*task.Desc, *task.ProjectID, *task.ID)
 	}
 
-	// 添加任务列表
-	if len(task.Items) > 0 {
-		content += "## 任务列表\n\n"
-		for _, item := range task.Items {
-			status := " "
-			if item.CompletedTime != nil {
-				status = "x"
-			}
-			title := ""
-			if item.Title != nil {
-				title = *item.Title
-			}
-			content += fmt.Sprintf("- [%s] %s\n", status, title)
-		}
-		content += "\n"
+	content, err := e.renderer.Render(tmplTask, data)
+	if err != nil {
+		return err
 	}
 
-	// 添加子任务列表
-	if len(task.ChildIDs) > 0 {
-		content += "## 子任务列表\n\n"
-		content += "```dataviewjs\n" +
-			"dv.view('dida365TaskTable', {\n" +
-			"    folderPath: '9.Archive/Dida365/Tasks',\n" +
-			"    condition: (p, c) => {\n" +
-			"        return p.frontmatter?.task_id === c.frontmatter?.parent_id;\n" +
-			"    }\n" +
-			"});\n" +
-			"```\n\n"
-		// content += e.createTableHeader()
-		// for _, childID := range task.ChildIDs {
-		// 	if childTask, exists := taskMap[childID]; exists {
-		// 		content += e.createTaskTableContent(childTask)
-		// 	} else {
-		// 		content += fmt.Sprintf("| %s | %s | %s | %s | %s |\n", childID, "", "", "", "")
-		// 	}
-		// }
-		// content += "\n"
-	}
-
-	// 添加父任务
-	// if task.ParentID != nil && *task.ParentID != "" {
-	// 	content += "## 父任务\n\n"
-	// 	content += e.createTableHeader()
-	// 	if parentTask, exists := taskMap[*task.ParentID]; exists {
-	// 		content += e.createTaskTableContent(parentTask)
-	// 	} else {
-	// 		content += fmt.Sprintf("| %s | %s | %s | %s | %s |\n", *task.ParentID, "", "", "", "")
-	// 	}
-	// 	content += "\n"
-	// }
-
-	// 删除旧文件并写入新文件
-	if _, err := os.Stat(filepath); err == nil {
-		os.Remove(filepath)
-		fmt.Printf("删除旧文件: %s\n", filename)
-	}
-
-	if err := os.WriteFile(filepath, []byte(content), 0644); err != nil {
+	// 保留分界标记之后用户添加的内容
+	content = withUserSection(content, filepath)
+
+	// 写入新内容，已有文件被原子地替换
+	_, statErr := os.Stat(filepath)
+	written, err := utils.WriteFile(filepath, []byte(content), 0644)
+	if err != nil {
 		return fmt.Errorf("写入任务文件失败: %v", err)
 	}
+	if written && statErr == nil {
+		fmt.Printf("已更新任务文件: %s\n", filename)
+	}
+	e.recordFile(kindTask, filepath, task, content)
 
 	// fmt.Printf("已创建任务文件: %s\n", filename)
 	return nil
 }
 
 // shouldSkipFile 检查是否应该跳过任务文件创建
-func (e *Dida365Exporter) shouldSkipFile(filepath string, task types.Task) bool {
-	if _, err := os.Stat(filepath); os.IsNotExist(err) {
+// 状态文件中记录的任务版本与输出路径未变化，且文件中生成的部分与上次写入的一致时跳过
+func (e *Dida365Exporter) shouldSkipFile(kind, filepath string, task types.Task) bool {
+	if !e.isUpToDate(state.Key(stateSource, kind, derefString(task.ID)), e.taskVersion(task), filepath) {
+		return false
+	}
+	// 之前导出时未下载的附件需要重新生成文件
+	if e.downloader != nil {
+		content, err := os.ReadFile(filepath)
+		if err != nil {
+			return false
+		}
+		if generated, _ := splitUserSection(string(content)); strings.Contains(generated, "dida365.com/api/v1/attachment/") {
+			return false
+		}
+	}
+	return true
+}
+
+// isUpToDate 判断文件是否为最新
+// 状态文件中记录的版本与输出路径未变化，且文件中生成的部分与上次写入的一致时返回 true
+func (e *Dida365Exporter) isUpToDate(key, version, filepath string) bool {
+	entity, ok := e.state.Entity(key)
+	if !ok || version == "" || entity.Version != version || entity.Path != relativePath(e.outputDir, filepath) {
 		return false
 	}
 
@@ -399,157 +443,145 @@ func (e *Dida365Exporter) shouldSkipFile(filepath string, task types.Task) bool
 	if err != nil {
 		return false
 	}
+	generated, _ := splitUserSection(string(content))
+	return state.Hash(generated) == entity.Hash
+}
+
+// recordFile 在状态文件中记录任务文件对应的任务版本、路径与生成内容的哈希
+func (e *Dida365Exporter) recordFile(kind, filepath string, task types.Task, content string) {
+	e.recordEntity(state.Key(stateSource, kind, derefString(task.ID)), e.taskVersion(task), filepath, content)
+}
+
+// recordEntity 在状态文件中记录文件对应的上游版本、路径与生成内容的哈希
+func (e *Dida365Exporter) recordEntity(key, version, filepath, content string) {
+	generated, _ := splitUserSection(content)
+	entity, _ := e.state.Entity(key)
+	entity.Version = version
+	entity.Path = relativePath(e.outputDir, filepath)
+	entity.Hash = state.Hash(generated)
+	e.state.SetEntity(key, entity)
+}
 
-	// 检查修改时间
-	if task.ModifiedTime != nil {
-		fileModifiedTime := utils.ExtractFrontMatterField(string(content), "modified_time")
-		taskModifiedTime := utils.FormatTime(*task.ModifiedTime, "2006-01-02 15:04:05")
-		return fileModifiedTime == taskModifiedTime
+// taskVersion 任务的版本，由 etag 与修改时间组成，没有修改时间时返回空字符串
+// 标签改名或调整层级不会更新任务的修改时间，因此同时包含任务的 Obsidian 标签
+func (e *Dida365Exporter) taskVersion(task types.Task) string {
+	if task.ModifiedTime == nil {
+		return ""
 	}
+	return fmt.Sprintf("%s@%s#%s", derefString(task.Etag), *task.ModifiedTime, strings.Join(e.obsidianTags(task.Tags), ","))
+}
 
-	return false
+// SaveState 保存本次导出的文件记录，保存成功后删除已导入的旧版本状态文件
+func (e *Dida365Exporter) SaveState() error {
+	if err := e.state.Save(); err != nil {
+		return err
+	}
+	for _, path := range e.legacyFiles {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			fmt.Printf("删除旧版本状态文件失败 %s: %v\n", path, err)
+		}
+	}
+	e.legacyFiles = nil
+	return nil
 }
 
 // buildTaskFrontMatter 构建任务的Front Matter
 func (e *Dida365Exporter) buildTaskFrontMatter(task types.Task) string {
-	frontMatter := ""
-	write := func(k string, v interface{}) {
-		if v != nil {
-			frontMatter += fmt.Sprintf("%s: %v\n", k, v)
-		}
-	}
-	write("title", *task.Title)
-	write("task_id", *task.ID)
-	write("project_id", *task.ProjectID)
-	write("column_id", *task.ColumnID)
+	frontMatter := utils.NewFrontMatter()
+	frontMatter.Set("title", derefString(task.Title))
+	frontMatter.Set("task_id", derefString(task.ID))
+	frontMatter.Set("project_id", derefString(task.ProjectID))
+	frontMatter.Set("column_id", derefString(task.ColumnID))
 	if task.ParentID != nil {
-		write("parent_id", *task.ParentID)
+		frontMatter.Set("parent_id", *task.ParentID)
 	}
-	write("priority", *task.Priority)
-	write("status", *task.Status)
-	if task.ProcessedStartDate != nil {
-		write("start_date", task.ProcessedStartDate.Format("2006-01-02 15:04:05"))
-	} else if task.StartDate != nil {
-		write("start_date", utils.FormatTime(*task.StartDate, "2006-01-02 15:04:05"))
+	if len(task.ChildIDs) > 0 {
+		frontMatter.Set("child_ids", task.ChildIDs)
 	}
-	if task.ProcessedDueDate != nil {
-		write("due_date", task.ProcessedDueDate.Format("2006-01-02 15:04:05"))
-	} else if task.DueDate != nil {
-		write("due_date", utils.FormatTime(*task.DueDate, "2006-01-02 15:04:05"))
+	if len(task.Tags) > 0 {
+		frontMatter.Set("tags", e.obsidianTags(task.Tags))
 	}
-	write("created_time", utils.FormatTime(*task.CreatedTime, "2006-01-02 15:04:05"))
-	write("modified_time", utils.FormatTime(*task.ModifiedTime, "2006-01-02 15:04:05"))
+	frontMatter.Set("priority", derefInt(task.Priority))
+	frontMatter.Set("status", derefInt(task.Status))
+	if startDate := taskStartDate(task); startDate != nil {
+		frontMatter.Set("start_date", startDate.Format("2006-01-02 15:04:05"))
+	}
+	if dueDate := taskDueDate(task); dueDate != nil {
+		frontMatter.Set("due_date", dueDate.Format("2006-01-02 15:04:05"))
+	}
+	frontMatter.Set("created_time", utils.FormatTime(derefString(task.CreatedTime), "2006-01-02 15:04:05"))
+	frontMatter.Set("modified_time", utils.FormatTime(derefString(task.ModifiedTime), "2006-01-02 15:04:05"))
 	if task.CompletedTime != nil {
-		write("completed_time", utils.FormatTime(*task.CompletedTime, "2006-01-02 15:04:05"))
+		frontMatter.Set("completed_time", utils.FormatTime(*task.CompletedTime, "2006-01-02 15:04:05"))
 	}
 	if task.RepeatFlag != nil {
-		write("repeat_flag", *task.RepeatFlag)
+		frontMatter.Set("repeat_flag", *task.RepeatFlag)
+		if recurrence := taskRecurrence(task); recurrence != "" {
+			frontMatter.Set("recurrence", recurrence)
+		}
 	}
-	content := utils.GetFrontMatter([]string{"noyaml"}, frontMatter)
-	content += fmt.Sprintf("# %s\n\n", *task.Title)
-	return content
+	frontMatter.SetDisplay("noyaml")
+	return frontMatter.String()
 }
 
-// getProjectIndexContent 获取项目索引内容
-func (e *Dida365Exporter) getProjectIndexContent(project types.Project, tasks []types.Task) string {
-	content := fmt.Sprintf("## %s\n\n", project.Name)
+// getProjectIndex 获取项目索引数据，任务按分组归类并按优先级排序
+func (e *Dida365Exporter) getProjectIndex(project types.Project, tasks []types.Task) ProjectIndex {
+	index := ProjectIndex{Project: project, Tasks: tasks}
 
-	if len(tasks) > 0 {
-		// 创建列映射，将任务按列分组
-		columnTasks := make(map[string][]types.Task)
-		
-		// 按列ID分组任务
-		for _, task := range tasks {
-			if task.ColumnID != nil && *task.ColumnID != "" {
-				columnTasks[*task.ColumnID] = append(columnTasks[*task.ColumnID], task)
-			}
-		}
-		
-		// 创建列ID到列名称的映射
-		columnNameMap := make(map[string]string)
-		for _, column := range project.Columns {
-			columnNameMap[*column.ID] = *column.Name
+	// 按列ID分组任务
+	columnTasks := make(map[string][]types.Task)
+	for _, task := range tasks {
+		if task.ColumnID != nil && *task.ColumnID != "" {
+			columnTasks[*task.ColumnID] = append(columnTasks[*task.ColumnID], task)
 		}
-		
-		// 按列显示任务
-		// 首先显示有列的任务
-		for _, column := range project.Columns {
-			tasksInColumn := columnTasks[*column.ID]
-			if len(tasksInColumn) > 0 {
-				// 显示列标题
-				content += fmt.Sprintf("### %s\n\n", *column.Name)
-				
-				// 对列内的任务按优先级排序
-				sort.Slice(tasksInColumn, func(i, j int) bool {
-					priI := 0
-					if tasksInColumn[i].Priority != nil {
-						priI = *tasksInColumn[i].Priority
-					}
-					priJ := 0
-					if tasksInColumn[j].Priority != nil {
-						priJ = *tasksInColumn[j].Priority
-					}
-					if priI != priJ {
-						return priI > priJ
-					}
-					// 如果优先级相同，按创建时间排序
-					createdI := ""
-					if tasksInColumn[i].CreatedTime != nil {
-						createdI = *tasksInColumn[i].CreatedTime
-					}
-					createdJ := ""
-					if tasksInColumn[j].CreatedTime != nil {
-						createdJ = *tasksInColumn[j].CreatedTime
-					}
-					return createdI < createdJ
-				})
-				
-				// 显示任务列表
-				for _, task := range tasksInColumn {
-					priorityMark := utils.GetPriorityMark(task.Priority)
-					timeRange := e.formatTaskTimeRange(task)
-					title := ""
-					if task.Title != nil {
-						title = *task.Title
-					}
-					id := ""
-					if task.ID != nil {
-						id = *task.ID
-					}
-					
-					if timeRange == "" {
-						content += fmt.Sprintf("- [ ] [[%s|%s]] | %s\n", id, title, priorityMark)
-					} else {
-						content += fmt.Sprintf("- [ ] [[%s|%s]] | %s | %s\n", id, title, priorityMark, timeRange)
-					}
-				}
-				content += "\n"
-			} else {
-				content += fmt.Sprintf("### %s\n\n", *column.Name)
+	}
+
+	for _, column := range project.Columns {
+		tasksInColumn := columnTasks[*column.ID]
+
+		// 对列内的任务按优先级排序
+		sort.Slice(tasksInColumn, func(i, j int) bool {
+			priI := 0
+			if tasksInColumn[i].Priority != nil {
+				priI = *tasksInColumn[i].Priority
 			}
-		}
+			priJ := 0
+			if tasksInColumn[j].Priority != nil {
+				priJ = *tasksInColumn[j].Priority
+			}
+			if priI != priJ {
+				return priI > priJ
+			}
+			// 如果优先级相同，按创建时间排序
+			createdI := ""
+			if tasksInColumn[i].CreatedTime != nil {
+				createdI = *tasksInColumn[i].CreatedTime
+			}
+			createdJ := ""
+			if tasksInColumn[j].CreatedTime != nil {
+				createdJ = *tasksInColumn[j].CreatedTime
+			}
+			return createdI < createdJ
+		})
+
+		index.Columns = append(index.Columns, ColumnTasks{Column: column, Tasks: tasksInColumn})
 	}
 
-	content += "\n"
-	return content
+	return index
 }
 
 // formatTaskTimeRange 格式化任务时间范围
-func (e *Dida365Exporter) formatTaskTimeRange(task types.Task) string {
+func formatTaskTimeRange(task types.Task) string {
 	var startDate, endDate string
 
 	// 处理开始时间
-	if task.ProcessedStartDate != nil {
-		startDate = task.ProcessedStartDate.Format("2006-01-02")
-	} else if task.StartDate != nil {
-		startDate = utils.FormatTime(*task.StartDate, "2006-01-02")
+	if start := taskStartDate(task); start != nil {
+		startDate = start.Format("2006-01-02")
 	}
 
 	// 处理结束时间
-	if task.ProcessedDueDate != nil {
-		endDate = task.ProcessedDueDate.Format("2006-01-02")
-	} else if task.DueDate != nil {
-		endDate = utils.FormatTime(*task.DueDate, "2006-01-02")
+	if due := taskDueDate(task); due != nil {
+		endDate = due.Format("2006-01-02")
 	}
 
 	if startDate != "" && endDate != "" {
@@ -584,7 +616,7 @@ func (e *Dida365Exporter) createTaskTableContent(task types.Task) string {
 
 	titleLink := fmt.Sprintf("[[%s\\|%s]]", id, title)
 	priorityMark := utils.GetPriorityMark(task.Priority)
-	timeRange := e.formatTaskTimeRange(task)
+	timeRange := formatTaskTimeRange(task)
 
 	status := "待办"
 	if task.Status != nil && *task.Status == 2 {
@@ -599,10 +631,10 @@ func (e *Dida365Exporter) createTaskTableContent(task types.Task) string {
 	return fmt.Sprintf("| %s | %s | %s | %s | %s |\n", titleLink, priorityMark, timeRange, status, doneTime)
 }
 
-// ExportDailySummary 导出每日摘要
-func (e *Dida365Exporter) ExportDailySummary(date time.Time, habits []types.Habit, checkins *types.HabitCheckinsResponse, todayStamp int) error {
+// ExportDailySummary 导出指定日期的每日摘要，习惯打卡状态取自 SetHabits 设置的打卡记录
+func (e *Dida365Exporter) ExportDailySummary(date time.Time) error {
 	// 设置日期范围
-	startDate := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.Local)
+	startDate := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, utils.Location())
 	endDate := startDate.Add(24*time.Hour - time.Second)
 
 	// 获取当日任务
@@ -612,112 +644,66 @@ func (e *Dida365Exporter) ExportDailySummary(date time.Time, habits []types.Habi
 	filename := fmt.Sprintf("%s-Dida365.md", date.Format("2006-01-02"))
 	filepath := filepath.Join(e.dailyDir, filename)
 
-	// 准备文件内容
-	content := utils.GetFrontMatter([]string{"noyaml"}, "")
-	// content += fmt.Sprintf("\n# %s 摘要\n\n", date.Format("2006-01-02"))
-
-	// 添加习惯打卡
-	if len(habits) > 0 {
-		content += "## 习惯打卡\n\n"
-		for _, habit := range habits {
-			checked := false
-			doneDate := ""
-
-			if checkins != nil && habit.ID != nil {
-				if habitCheckins, exists := checkins.Checkins[*habit.ID]; exists {
-					for _, checkin := range habitCheckins {
-						if checkin.CheckinStamp != nil && *checkin.CheckinStamp == todayStamp &&
-							checkin.Status != nil && *checkin.Status == 2 {
-							checked = true
-							if checkin.CheckinTime != nil {
-								doneDate = utils.FormatTime(*checkin.CheckinTime, "2006-01-02")
-							}
-							break
-						}
-					}
-				}
-			}
+	// 准备模板数据
+	data := DailySummaryData{
+		Date:        date,
+		FrontMatter: displayFrontMatter("noyaml"),
+		Tasks:       tasks,
+		TodoTasks:   make([]types.Task, 0),
+		DoneTasks:   make([]types.Task, 0),
+	}
 
-			habitName := ""
-			if habit.Name != nil {
-				habitName = *habit.Name
-			}
+	// 计算习惯打卡状态
+	data.Habits = e.habitStatuses(startDate)
 
-			if checked {
-				content += fmt.Sprintf("- [x] %s | ✅ %s\n", habitName, doneDate)
-			} else {
-				content += fmt.Sprintf("- [ ] %s\n", habitName)
-			}
+	// 分离待办和已完成任务
+	for _, task := range tasks {
+		if task.Status != nil && *task.Status == 0 {
+			data.TodoTasks = append(data.TodoTasks, task)
+		} else if task.Status != nil && *task.Status == 2 {
+			data.DoneTasks = append(data.DoneTasks, task)
 		}
-		content += "\n"
 	}
 
-	if len(tasks) > 0 {
-		// 分离待办和已完成任务
-		todoTasks := make([]types.Task, 0)
-		doneTasks := make([]types.Task, 0)
-
-		for _, task := range tasks {
-			if task.Status != nil && *task.Status == 0 {
-				todoTasks = append(todoTasks, task)
-			} else if task.Status != nil && *task.Status == 2 {
-				doneTasks = append(doneTasks, task)
+	// 按优先级排序
+	for _, list := range [][]types.Task{data.TodoTasks, data.DoneTasks} {
+		sort.Slice(list, func(i, j int) bool {
+			priI := 0
+			if list[i].Priority != nil {
+				priI = *list[i].Priority
 			}
-		}
-
-		// 输出待办任务
-		if len(todoTasks) > 0 {
-			content += "## 待办任务\n\n"
-			// 按优先级排序
-			sort.Slice(todoTasks, func(i, j int) bool {
-				priI := 0
-				if todoTasks[i].Priority != nil {
-					priI = *todoTasks[i].Priority
-				}
-				priJ := 0
-				if todoTasks[j].Priority != nil {
-					priJ = *todoTasks[j].Priority
-				}
-				return priI > priJ
-			})
-
-			for idx, task := range todoTasks {
-				content += e.formatTaskLine(task, idx+1, true) + "\n"
+			priJ := 0
+			if list[j].Priority != nil {
+				priJ = *list[j].Priority
 			}
-			content += "\n"
-		}
+			return priI > priJ
+		})
+	}
 
-		// 输出已完成任务
-		if len(doneTasks) > 0 {
-			content += "## 已完成任务\n\n"
-			// 按优先级排序
-			sort.Slice(doneTasks, func(i, j int) bool {
-				priI := 0
-				if doneTasks[i].Priority != nil {
-					priI = *doneTasks[i].Priority
-				}
-				priJ := 0
-				if doneTasks[j].Priority != nil {
-					priJ = *doneTasks[j].Priority
-				}
-				return priI > priJ
-			})
+	content, err := e.renderer.Render(tmplDaily, data)
+	if err != nil {
+		return err
+	}
 
-			for _, task := range doneTasks {
-				content += e.formatTaskLine(task, 0, false) + "\n"
-			}
-			content += "\n"
+	// 写入用户的每日笔记
+	if mergeDailyNote() {
+		if err := e.dailyNote.Merge(date, sectionDida365, content); err != nil {
+			return err
 		}
-	} else {
-		content += "今日没有任务。\n"
+	}
+	if !writeSeparateDaily() {
+		return nil
 	}
 
 	// 写入文件
-	if err := os.WriteFile(filepath, []byte(content), 0644); err != nil {
+	written, err := utils.WriteFile(filepath, []byte(content), 0644)
+	if err != nil {
 		return fmt.Errorf("写入每日摘要失败: %v", err)
 	}
 
-	fmt.Printf("已创建每日摘要：%s\n", filename)
+	if written {
+		fmt.Printf("已创建每日摘要：%s\n", filename)
+	}
 	return nil
 }
 
@@ -739,32 +725,25 @@ func (e *Dida365Exporter) getTasksInDateRange(startDate, endDate time.Time) []ty
 		}
 	}
 
+	// 处理重复任务的后续重复
+	tasks = append(tasks, e.recurringOccurrences(startDate, endDate)...)
+
 	return tasks
 }
 
 // taskInRange 判断任务是否在指定时间范围内
 func (e *Dida365Exporter) taskInRange(task types.Task, start, end time.Time) bool {
-	var taskStart, taskEnd *time.Time
-
-	// 获取任务开始时间
-	if task.ProcessedStartDate != nil {
-		taskStart = task.ProcessedStartDate
-	} else if task.StartDate != nil {
-		if parsed := utils.ParseDateTime(*task.StartDate); parsed != nil {
-			taskStart = parsed
-		}
-	}
-
-	// 获取任务结束时间
-	if task.ProcessedDueDate != nil {
-		taskEnd = task.ProcessedDueDate
-	} else if task.DueDate != nil {
-		if parsed := utils.ParseDateTime(*task.DueDate); parsed != nil {
-			taskEnd = parsed
-		}
-	}
+	// 获取任务开始与结束时间
+	taskStart := taskStartDate(task)
+	taskEnd := taskDueDate(task)
 
 	if taskStart == nil && taskEnd == nil {
+		// 没有日期的已完成任务按完成时间归入对应日期
+		if task.Status != nil && *task.Status == 2 && task.CompletedTime != nil {
+			if completed := utils.ParseDateTime(*task.CompletedTime); completed != nil {
+				return !completed.Before(start) && !completed.After(end)
+			}
+		}
 		return false
 	}
 
@@ -780,9 +759,18 @@ func (e *Dida365Exporter) taskInRange(task types.Task, start, end time.Time) boo
 }
 
 // formatTaskLine 格式化任务行
-func (e *Dida365Exporter) formatTaskLine(task types.Task, index int, ordered bool) string {
+func formatTaskLine(task types.Task, index int, ordered bool) string {
 	priorityMark := utils.GetPriorityMark(task.Priority)
-	timeRange := e.formatTaskTimeRange(task)
+	timeRange := formatTaskTimeRange(task)
+
+	checkbox := "[ ]"
+	if task.Status != nil && *task.Status == 2 {
+		checkbox = "[x]"
+	}
+	// 重复任务的后续重复不是实际的任务，不输出复选框，避免勾选后被同步为完成整个重复任务
+	if task.Occurrence {
+		checkbox = "🔁"
+	}
 
 	var line string
 	if ordered && index > 0 {
@@ -794,12 +782,8 @@ func (e *Dida365Exporter) formatTaskLine(task types.Task, index int, ordered boo
 		if task.ID != nil {
 			id = *task.ID
 		}
-		line = fmt.Sprintf("%d. [[%s|%s]] | %s", index, id, title, priorityMark)
+		line = fmt.Sprintf("%d. %s [[%s|%s]] | %s", index, checkbox, id, title, priorityMark)
 	} else {
-		checkbox := " "
-		if task.Status != nil && *task.Status == 2 {
-			checkbox = "x"
-		}
 		title := ""
 		if task.Title != nil {
 			title = *task.Title
@@ -808,7 +792,7 @@ func (e *Dida365Exporter) formatTaskLine(task types.Task, index int, ordered boo
 		if task.ID != nil {
 			id = *task.ID
 		}
-		line = fmt.Sprintf("- [%s] [[%s|%s]] | %s", checkbox, id, title, priorityMark)
+		line = fmt.Sprintf("- %s [[%s|%s]] | %s", checkbox, id, title, priorityMark)
 	}
 
 	if timeRange != "" {
@@ -827,130 +811,146 @@ func (e *Dida365Exporter) formatTaskLine(task types.Task, index int, ordered boo
 	return line
 }
 
+// formatIndexLine 格式化项目索引中的任务行，如 "- [ ] [[id|标题]] | ⏫ | 📅 2006-01-02"
+func formatIndexLine(task types.Task) string {
+	line := fmt.Sprintf("- [ ] [[%s|%s]] | %s", derefString(task.ID), derefString(task.Title), utils.GetPriorityMark(task.Priority))
+	if timeRange := formatTaskTimeRange(task); timeRange != "" {
+		line += fmt.Sprintf(" | %s", timeRange)
+	}
+	return line
+}
+
 // ExportWeeklySummary 导出每周摘要
 func (e *Dida365Exporter) ExportWeeklySummary(date time.Time) error {
 	// 获取周的开始和结束日期（周一到周日）
-	weekday := int(date.Weekday())
-	if weekday == 0 {
-		weekday = 7 // 将周日从0改为7
-	}
-	startOfWeek := date.AddDate(0, 0, -(weekday - 1))
-	endOfWeek := startOfWeek.AddDate(0, 0, 6)
+	startOfWeek := utils.StartOfWeek(date)
+	endOfWeek := startOfWeek.AddDate(0, 0, 7).Add(-time.Second)
 
 	// 创建文件名
-	year, week := time.Now().ISOWeek()
+	year, week := date.ISOWeek()
 	filename := fmt.Sprintf("%d-W%d-Dida365.md", year, week)
 	filepath := filepath.Join(e.weeklyDir, filename)
 
-	if e.skip(filepath) {
-		// fmt.Printf("文件已存在: %s\n", filename)
-		return nil
-	}
+	// 按天分组统计任务，每次导出都重新生成
+	data := PeriodSummaryData{
+		Start:       startOfWeek,
+		End:         endOfWeek,
+		Year:        year,
+		Week:        week,
+		FrontMatter: displayFrontMatter("fullwidth", "noyaml"),
+	}
+	groups := weekGroups(startOfWeek)
+	e.periodSummary(&data, groups, func(task types.Task) int {
+		return groupIndex(groups, e.getTaskDate(task, startOfWeek, endOfWeek))
+	})
 
-	// 准备文件内容
-	content := utils.GetFrontMatter([]string{"fullwidth", "noyaml"}, "")
-	content += fmt.Sprintf("# %d年第%02d周任务摘要\n\n", year, week)
-	content += fmt.Sprintf("周期： %s 至 %s \n\n", startOfWeek.Format("2006-01-02"), endOfWeek.Format("2006-01-02"))
-	content += e.dataviewjs(startOfWeek, endOfWeek)
+	content, err := e.renderer.Render(tmplWeekly, data)
+	if err != nil {
+		return err
+	}
 
 	// 写入文件
-	if err := os.WriteFile(filepath, []byte(content), 0644); err != nil {
+	written, err := utils.WriteFile(filepath, []byte(content), 0644)
+	if err != nil {
 		return fmt.Errorf("写入每周摘要失败: %v", err)
 	}
 
-	fmt.Printf("已创建每周摘要：%s\n", filename)
+	if written {
+		fmt.Printf("已创建每周摘要：%s\n", filename)
+	}
 	return nil
 }
 
 // ExportMonthlySummary 导出每月摘要
 func (e *Dida365Exporter) ExportMonthlySummary(date time.Time) error {
 	// 计算月份的第一天和最后一天
-	firstDay := time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, time.Local)
+	firstDay := time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, utils.Location())
 	var lastDay time.Time
 	if date.Month() == time.December {
-		lastDay = time.Date(date.Year()+1, time.January, 1, 23, 59, 59, 0, time.Local).AddDate(0, 0, -1)
+		lastDay = time.Date(date.Year()+1, time.January, 1, 23, 59, 59, 0, utils.Location()).AddDate(0, 0, -1)
 	} else {
-		lastDay = time.Date(date.Year(), date.Month()+1, 1, 23, 59, 59, 0, time.Local).AddDate(0, 0, -1)
+		lastDay = time.Date(date.Year(), date.Month()+1, 1, 23, 59, 59, 0, utils.Location()).AddDate(0, 0, -1)
 	}
 
 	// 创建目录路径
 	filename := fmt.Sprintf("%s-Dida365.md", date.Format("2006-01"))
 	filepath := filepath.Join(e.monthlyDir, filename)
 
-	if e.skip(filepath) {
-		// fmt.Printf("文件已存在: %s\n", filename)
-		return nil
-	}
+	// 按周分组统计任务，每次导出都重新生成
+	year, week := firstDay.ISOWeek()
+	data := PeriodSummaryData{
+		Start:       firstDay,
+		End:         lastDay,
+		Year:        year,
+		Week:        week,
+		FrontMatter: displayFrontMatter("fullwidth", "noyaml"),
+	}
+	groups := monthGroups(firstDay, lastDay)
+	e.periodSummary(&data, groups, func(task types.Task) int {
+		return groupIndex(groups, e.getTaskDate(task, firstDay, lastDay))
+	})
 
-	// 构建Markdown内容
-	content := utils.GetFrontMatter([]string{"fullwidth", "noyaml"}, "")
-	content += fmt.Sprintf("# %s任务摘要\n\n", date.Format("2006年01月"))
-	content += e.dataviewjs(firstDay, lastDay)
+	content, err := e.renderer.Render(tmplMonthly, data)
+	if err != nil {
+		return err
+	}
 
 	// 写入文件
-	if err := os.WriteFile(filepath, []byte(content), 0644); err != nil {
+	written, err := utils.WriteFile(filepath, []byte(content), 0644)
+	if err != nil {
 		return fmt.Errorf("写入每月摘要失败: %v", err)
 	}
 
-	fmt.Printf("已创建每月摘要：%s\n", filename)
+	if written {
+		fmt.Printf("已创建每月摘要：%s\n", filename)
+	}
 	return nil
 }
 
-func (e *Dida365Exporter) skip(filepath string) bool {
-	if _, err := os.Stat(filepath); os.IsNotExist(err) {
-		return false
+// derefString 读取字符串指针，nil 时返回空字符串
+func derefString(value *string) string {
+	if value == nil {
+		return ""
 	}
-	return true
-}
-
-func (e *Dida365Exporter) dataviewjs(start time.Time, end time.Time) string { 
-	content := "```dataviewjs\n" +
-            "dv.view('dida365TaskTable', {\n" +
-            "    folderPath: '9.Archive/Dida365/Tasks',\n" +
-            "    condition: (p, c) => {\n" +
-			"        const rawDue = p.frontmatter?.due_date;\n" +
-			"        const rawStart = p.frontmatter?.start_date;\n" +
-            "        const normalize = v => {\n" +
-			"            if (!v) return null;\n" +
-			"            const str = String(v);\n" +
-			"            const m = str.match(/^(\\d{4}-\\d{2}-\\d{2})/);\n" +
-			"			 return m ? m[1] : null;\n" +
-			"        };\n" +
-			"        const dueStr = normalize(rawDue);\n" +
-			"        const startStr = normalize(rawStart);\n" +
-			"		 const dueDate   = dv.date(dueStr);\n" +
-			"		 const startDate = dv.date(startStr);\n" +
-			fmt.Sprintf("        const weekStart = dv.date('%s');\n", start.Format("2006-01-02")) +
-			fmt.Sprintf("        const weekEnd   = dv.date('%s');\n", end.Format("2006-01-02")) +
-			"        const inWeek = d => d && d >= weekStart && d <= weekEnd;\n" +
-			"        return inWeek(dueDate) || inWeek(startDate);\n" +
-            "    }\n" +
-            "});\n" +
-            "```\n\n"
-	return content
+	return *value
 }
 
-// getTaskDate 获取任务所属的日期
-func (e *Dida365Exporter) getTaskDate(task types.Task, startDate, endDate time.Time) string {
-	var taskStart, taskEnd *time.Time
 
-	// 获取任务开始时间
+// taskStartDate 获取任务的开始时间，全天任务按任务自身时区确定日期
+func taskStartDate(task types.Task) *time.Time {
 	if task.ProcessedStartDate != nil {
-		taskStart = task.ProcessedStartDate
-	} else if task.StartDate != nil {
-		if parsed := utils.ParseDateTime(*task.StartDate); parsed != nil {
-			taskStart = parsed
-		}
+		return task.ProcessedStartDate
+	}
+	if task.StartDate == nil {
+		return nil
 	}
+	return utils.ParseTaskDate(*task.StartDate, derefString(task.TimeZone), task.IsAllDay != nil && *task.IsAllDay)
+}
 
-	// 获取任务结束时间
+// taskDueDate 获取任务的截止时间，全天任务按任务自身时区确定日期
+func taskDueDate(task types.Task) *time.Time {
 	if task.ProcessedDueDate != nil {
-		taskEnd = task.ProcessedDueDate
-	} else if task.DueDate != nil {
-		if parsed := utils.ParseDateTime(*task.DueDate); parsed != nil {
-			taskEnd = parsed
-		}
+		return task.ProcessedDueDate
+	}
+	if task.DueDate == nil {
+		return nil
+	}
+	return utils.ParseTaskDate(*task.DueDate, derefString(task.TimeZone), task.IsAllDay != nil && *task.IsAllDay)
+}
+// derefInt 读取整数指针，nil 时返回 0
+func derefInt(value *int) int {
+	if value == nil {
+		return 0
 	}
+	return *value
+}
+
+// getTaskDate 获取任务所属的日期
+// 依次使用落在范围内的截止时间、开始时间，没有日期的已完成任务使用完成时间
+func (e *Dida365Exporter) getTaskDate(task types.Task, startDate, endDate time.Time) string {
+	// 获取任务开始与结束时间
+	taskStart := taskStartDate(task)
+	taskEnd := taskDueDate(task)
 
 	// 如果有结束时间，使用结束时间的日期
 	if taskEnd != nil && !taskEnd.Before(startDate) && !taskEnd.After(endDate) {
@@ -962,42 +962,12 @@ func (e *Dida365Exporter) getTaskDate(task types.Task, startDate, endDate time.T
 		return taskStart.Format("2006-01-02")
 	}
 
-	return ""
-}
-
-// getTaskWeek 获取任务所属的周
-func (e *Dida365Exporter) getTaskWeek(task types.Task, startOfMonth time.Time) string {
-	var taskTime *time.Time
-
-	// 优先使用结束时间，然后是开始时间
-	if task.ProcessedDueDate != nil {
-		taskTime = task.ProcessedDueDate
-	} else if task.DueDate != nil {
-		if parsed := utils.ParseDateTime(*task.DueDate); parsed != nil {
-			taskTime = parsed
-		}
-	} else if task.ProcessedStartDate != nil {
-		taskTime = task.ProcessedStartDate
-	} else if task.StartDate != nil {
-		if parsed := utils.ParseDateTime(*task.StartDate); parsed != nil {
-			taskTime = parsed
+	// 没有日期的已完成任务按完成时间归入对应日期
+	if taskStart == nil && taskEnd == nil && derefInt(task.Status) == 2 && task.CompletedTime != nil {
+		if completed := utils.ParseDateTime(*task.CompletedTime); completed != nil && !completed.Before(startDate) && !completed.After(endDate) {
+			return completed.Format("2006-01-02")
 		}
 	}
 
-	if taskTime == nil {
-		return ""
-	}
-
-	// 计算是第几周
-	weekday := int(taskTime.Weekday())
-	if weekday == 0 {
-		weekday = 7
-	}
-	startOfWeek := taskTime.AddDate(0, 0, -(weekday - 1))
-	endOfWeek := startOfWeek.AddDate(0, 0, 6)
-
-	// 计算周数
-	weekNum := (startOfWeek.Day()-1)/7 + 1
-
-	return fmt.Sprintf("第%d周 (%s ~ %s)", weekNum, startOfWeek.Format("01-02"), endOfWeek.Format("01-02"))
+	return ""
 }
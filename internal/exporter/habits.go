@@ -0,0 +1,428 @@
+package exporter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"exporter-to-obsidian/internal/rrule"
+	"exporter-to-obsidian/internal/types"
+	"exporter-to-obsidian/internal/utils"
+)
+
+// HabitDay 习惯在某一天的打卡情况
+type HabitDay struct {
+	Date    time.Time
+	Due     bool // 按重复规则当天是否需要打卡
+	Checked bool
+	Failed  bool    // 当天被标记为未完成
+	Value   float64 // 数值型习惯当天记录的数值
+}
+
+// HabitMonth 习惯笔记中一个月的打卡日历
+type HabitMonth struct {
+	Month time.Time
+	Weeks [][]string // 每行为一周（周一开始）七天的单元格内容
+}
+
+// HabitNoteData 习惯笔记模板数据
+type HabitNoteData struct {
+	Habit          types.Habit
+	Title          string
+	FrontMatter    string
+	Rule           string    // 重复规则的中文描述
+	Start          time.Time // 统计范围的第一天
+	End            time.Time // 统计范围的最后一天（今天）
+	Checked        int       // 已完成的打卡次数
+	Due            int       // 应完成的打卡次数，今天（本周）尚未完成的部分不计入
+	Rate           int       // 完成率（百分比）
+	CurrentStreak  int
+	LongestStreak  int
+	StreakUnit     string // 连续打卡的单位，按次数打卡的习惯以周计算
+	TargetDays     int
+	TargetProgress int // 自目标开始日期以来的打卡天数
+	Numeric        bool
+	Goal           float64
+	Unit           string
+	TotalValue     float64
+	Months         []HabitMonth // 打卡日历，最近的月份在前
+}
+
+// HabitHistoryStart 习惯笔记统计范围的第一天，由 HABIT_HISTORY_DAYS（默认 365）决定
+func HabitHistoryStart() time.Time {
+	days, err := strconv.Atoi(utils.GetEnvOrDefault("HABIT_HISTORY_DAYS", "365"))
+	if err != nil || days < 1 {
+		days = 365
+	}
+	return utils.StartOfDay(utils.Now()).AddDate(0, 0, 1-days)
+}
+
+// ExportHabits 为每个习惯生成一篇笔记，包含连续打卡、完成率与打卡日历
+func (e *Dida365Exporter) ExportHabits() error {
+	if len(e.habits) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(e.habitsDir, 0755); err != nil {
+		return fmt.Errorf("创建习惯目录失败: %v", err)
+	}
+
+	// 同名的习惯在文件名后附加习惯ID，避免互相覆盖
+	names := make(map[string]int)
+	for _, habit := range e.habits {
+		names[tagFileName(derefString(habit.Name))]++
+	}
+
+	for _, habit := range e.habits {
+		if err := e.createHabitMarkdown(habit, names[tagFileName(derefString(habit.Name))] > 1); err != nil {
+			fmt.Printf("创建习惯文件失败: %v\n", err)
+			return fmt.Errorf("创建习惯文件失败: %v", err)
+		}
+	}
+	return nil
+}
+
+// createHabitMarkdown 为单个习惯创建Markdown文件，duplicate 表示有其他习惯与其同名
+func (e *Dida365Exporter) createHabitMarkdown(habit types.Habit, duplicate bool) error {
+	if habit.ID == nil || *habit.ID == "" {
+		return fmt.Errorf("习惯ID为空")
+	}
+
+	name := derefString(habit.Name)
+	filename := fmt.Sprintf("%s.md", habitFileName(habit, duplicate))
+	filepath := filepath.Join(e.habitsDir, filename)
+	e.track(kindHabit, *habit.ID, "", derefInt(habit.Status), filepath)
+
+	today := utils.StartOfDay(utils.Now())
+	start := HabitHistoryStart()
+	if habit.CreatedTime != nil {
+		if created := utils.ParseDateTime(*habit.CreatedTime); created != nil && utils.StartOfDay(*created).After(start) {
+			start = utils.StartOfDay(*created)
+		}
+	}
+
+	data := HabitNoteData{
+		Habit:      habit,
+		Title:      name,
+		Start:      start,
+		End:        today,
+		TargetDays: derefInt(habit.TargetDays),
+		Numeric:    isNumericHabit(habit),
+		Unit:       derefString(habit.Unit),
+	}
+	if habit.Goal != nil {
+		data.Goal = *habit.Goal
+	}
+	days := e.habitDays(habit, start, today)
+	rule := habitRule(habit)
+	if rule != nil {
+		data.Rule = rule.String()
+	}
+	if rule != nil && rule.Times > 0 {
+		data.StreakUnit = "周"
+		weeklyHabitStats(&data, days, rule.Times, today)
+	} else {
+		data.StreakUnit = "天"
+		dailyHabitStats(&data, days, today)
+	}
+	for _, day := range days {
+		data.TotalValue += day.Value
+	}
+	if data.Due > 0 {
+		data.Rate = data.Checked * 100 / data.Due
+	}
+	if data.TargetDays > 0 {
+		data.TargetProgress = e.habitCheckedSince(habit, derefInt(habit.TargetStartDate))
+	}
+	data.Months = habitMonths(days, data.Numeric)
+
+	frontMatter := utils.NewFrontMatter()
+	frontMatter.Set("title", name)
+	frontMatter.Set("habit_id", derefString(habit.ID))
+	if habit.Type != nil {
+		frontMatter.Set("type", *habit.Type)
+	}
+	if data.Rule != "" {
+		frontMatter.Set("repeat", data.Rule)
+	}
+	if data.Numeric {
+		frontMatter.Set("goal", formatNumber(data.Goal))
+		frontMatter.Set("unit", data.Unit)
+	}
+	if habit.Color != nil {
+		frontMatter.Set("color", *habit.Color)
+	}
+	frontMatter.Set("current_streak", data.CurrentStreak)
+	frontMatter.Set("longest_streak", data.LongestStreak)
+	frontMatter.Set("completion_rate", data.Rate)
+	if habit.TotalCheckIns != nil {
+		frontMatter.Set("total_checkins", *habit.TotalCheckIns)
+	}
+	frontMatter.SetDisplay("noyaml")
+	data.FrontMatter = frontMatter.String()
+
+	content, err := e.renderer.Render(tmplHabit, data)
+	if err != nil {
+		return err
+	}
+
+	if _, err := utils.WriteFile(filepath, []byte(content), 0644); err != nil {
+		return fmt.Errorf("写入习惯文件失败: %v", err)
+	}
+	return nil
+}
+
+// habitFileName 习惯笔记的文件名（不含扩展名），默认为习惯名称
+// 名称为空时使用习惯ID，与其他习惯同名时在名称后附加习惯ID
+func habitFileName(habit types.Habit, duplicate bool) string {
+	name := strings.TrimSpace(tagFileName(derefString(habit.Name)))
+	switch {
+	case name == "":
+		return derefString(habit.ID)
+	case duplicate:
+		return fmt.Sprintf("%s-%s", name, derefString(habit.ID))
+	default:
+		return name
+	}
+}
+
+// dailyHabitStats 按天统计完成率与连续打卡，无需打卡的日子不会中断连续打卡，今天尚未打卡时不计入
+func dailyHabitStats(data *HabitNoteData, days []HabitDay, today time.Time) {
+	streak := 0
+	for _, day := range days {
+		switch {
+		case day.Checked:
+			streak++
+		case day.Due && day.Date.Before(today):
+			streak = 0
+		}
+		if streak > data.LongestStreak {
+			data.LongestStreak = streak
+		}
+		if day.Due && (day.Checked || day.Date.Before(today)) {
+			data.Due++
+			if day.Checked {
+				data.Checked++
+			}
+		}
+	}
+	data.CurrentStreak = streak
+}
+
+// weeklyHabitStats 按周统计每周需完成 times 次的习惯，连续打卡为连续达成的周数，本周尚未达成时不计入
+func weeklyHabitStats(data *HabitNoteData, days []HabitDay, times int, today time.Time) {
+	streak := 0
+	for i := 0; i < len(days); {
+		week := utils.StartOfWeek(days[i].Date)
+		checked := 0
+		for ; i < len(days) && utils.StartOfWeek(days[i].Date).Equal(week); i++ {
+			if days[i].Checked {
+				checked++
+			}
+		}
+		if checked > times {
+			checked = times
+		}
+
+		finished := week.AddDate(0, 0, 7).Before(today) || week.AddDate(0, 0, 7).Equal(today)
+		switch {
+		case checked == times:
+			streak++
+		case finished:
+			streak = 0
+		}
+		if streak > data.LongestStreak {
+			data.LongestStreak = streak
+		}
+		data.Checked += checked
+		if finished {
+			data.Due += times
+		} else {
+			data.Due += checked
+		}
+	}
+	data.CurrentStreak = streak
+}
+
+// habitDays 返回习惯在 [start, end] 内每一天的打卡情况
+func (e *Dida365Exporter) habitDays(habit types.Habit, start, end time.Time) []HabitDay {
+	checkins := make(map[int]types.HabitCheckin)
+	if e.checkins != nil && habit.ID != nil {
+		for _, checkin := range e.checkins.Checkins[*habit.ID] {
+			if checkin.CheckinStamp != nil {
+				checkins[*checkin.CheckinStamp] = checkin
+			}
+		}
+	}
+
+	due := habitDueDates(habit, start, end)
+	var days []HabitDay
+	for date := utils.StartOfDay(start); !date.After(end); date = date.AddDate(0, 0, 1) {
+		stamp := utils.DateStamp(date)
+		day := HabitDay{Date: date, Due: due == nil || due[stamp]}
+		if checkin, ok := checkins[stamp]; ok {
+			day.Checked = derefInt(checkin.Status) == 2
+			day.Failed = derefInt(checkin.Status) == 1
+			if checkin.Value != nil {
+				day.Value = *checkin.Value
+			}
+		}
+		days = append(days, day)
+	}
+	return days
+}
+
+// habitStatuses 返回指定日期各习惯的打卡状态，跳过当天尚未创建或已归档的习惯
+func (e *Dida365Exporter) habitStatuses(date time.Time) []HabitStatus {
+	stamp := utils.DateStamp(date)
+	var statuses []HabitStatus
+	for _, habit := range e.habits {
+		if !habitActiveOn(habit, date) {
+			continue
+		}
+
+		status := HabitStatus{Habit: habit, Due: true}
+		if due := habitDueDates(habit, date, date); due != nil {
+			status.Due = due[stamp]
+		}
+		if e.checkins != nil && habit.ID != nil {
+			for _, checkin := range e.checkins.Checkins[*habit.ID] {
+				if checkin.CheckinStamp == nil || *checkin.CheckinStamp != stamp {
+					continue
+				}
+				if checkin.Value != nil {
+					status.Value = *checkin.Value
+				}
+				if derefInt(checkin.Status) == 2 {
+					status.Checked = true
+					status.DoneDate = date.Format("2006-01-02")
+					if checkin.CheckinTime != nil {
+						status.DoneDate = utils.FormatTime(*checkin.CheckinTime, "2006-01-02")
+					}
+				}
+				break
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// habitActiveOn 习惯在指定日期是否已创建且尚未归档
+func habitActiveOn(habit types.Habit, date time.Time) bool {
+	day := utils.StartOfDay(date)
+	if habit.CreatedTime != nil {
+		if created := utils.ParseDateTime(*habit.CreatedTime); created != nil && utils.StartOfDay(*created).After(day) {
+			return false
+		}
+	}
+	if habit.ArchivedTime != nil {
+		if archived := utils.ParseDateTime(*habit.ArchivedTime); archived != nil && utils.StartOfDay(*archived).Before(day) {
+			return false
+		}
+	}
+	return true
+}
+
+// habitCheckedSince 统计从日期戳 since 开始已打卡的天数
+func (e *Dida365Exporter) habitCheckedSince(habit types.Habit, since int) int {
+	if e.checkins == nil || habit.ID == nil {
+		return 0
+	}
+	count := 0
+	for _, checkin := range e.checkins.Checkins[*habit.ID] {
+		if checkin.CheckinStamp != nil && *checkin.CheckinStamp >= since && derefInt(checkin.Status) == 2 {
+			count++
+		}
+	}
+	return count
+}
+
+// habitRule 解析习惯的重复规则，没有规则或无法解析时返回 nil
+func habitRule(habit types.Habit) *rrule.Rule {
+	if habit.RepeatRule == nil || *habit.RepeatRule == "" {
+		return nil
+	}
+	rule, err := rrule.Parse(*habit.RepeatRule, utils.Location())
+	if err != nil {
+		return nil
+	}
+	return rule
+}
+
+// habitDueDates 返回习惯在 [start, end] 内需要打卡的日期戳
+// 每天都需要打卡、按次数打卡（每周 N 次）或规则无法展开时返回 nil，表示每天都可打卡
+func habitDueDates(habit types.Habit, start, end time.Time) map[int]bool {
+	rule := habitRule(habit)
+	if rule == nil || rule.Times > 0 || !rule.Expandable() {
+		return nil
+	}
+
+	// 重复规则从习惯创建的那一天起计算，没有创建时间时从统计范围的第一天起计算
+	anchor := utils.StartOfDay(start)
+	if habit.CreatedTime != nil {
+		if created := utils.ParseDateTime(*habit.CreatedTime); created != nil && created.Before(anchor) {
+			anchor = utils.StartOfDay(*created)
+		}
+	}
+
+	due := make(map[int]bool)
+	for _, date := range rule.Between(anchor, utils.StartOfDay(start), end, nil) {
+		due[utils.DateStamp(date)] = true
+	}
+	return due
+}
+
+// isNumericHabit 是否为记录数值的习惯（如每天喝水 8 杯）
+func isNumericHabit(habit types.Habit) bool {
+	return strings.EqualFold(derefString(habit.Type), "Real")
+}
+
+// habitMonths 将每天的打卡情况排列为按月的日历，最近的月份在前
+func habitMonths(days []HabitDay, numeric bool) []HabitMonth {
+	var months []HabitMonth
+	for i := 0; i < len(days); {
+		first := days[i].Date
+		month := HabitMonth{Month: time.Date(first.Year(), first.Month(), 1, 0, 0, 0, 0, utils.Location())}
+
+		week := make([]string, 7)
+		for ; i < len(days) && days[i].Date.Month() == first.Month(); i++ {
+			day := days[i]
+			column := (int(day.Date.Weekday()) + 6) % 7
+			week[column] = habitCell(day, numeric)
+			if column == 6 {
+				month.Weeks = append(month.Weeks, week)
+				week = make([]string, 7)
+			}
+		}
+		if strings.Join(week, "") != "" {
+			month.Weeks = append(month.Weeks, week)
+		}
+		months = append([]HabitMonth{month}, months...)
+	}
+	return months
+}
+
+// habitCell 打卡日历中一天的单元格，如 "5 ✅"、"6 ❌"、"7 ➖"（无需打卡）
+func habitCell(day HabitDay, numeric bool) string {
+	cell := strconv.Itoa(day.Date.Day())
+	switch {
+	case day.Checked:
+		cell += " ✅"
+	case day.Failed:
+		cell += " ❌"
+	case !day.Due:
+		cell += " ➖"
+	}
+	if numeric && day.Value > 0 {
+		cell += " " + formatNumber(day.Value)
+	}
+	return cell
+}
+
+// formatNumber 格式化数值，去掉多余的小数位
+func formatNumber(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
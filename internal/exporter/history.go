@@ -0,0 +1,137 @@
+package exporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"exporter-to-obsidian/internal/types"
+	"exporter-to-obsidian/internal/utils"
+)
+
+// History 本地保存的习惯、打卡记录与任务，用于在不访问滴答清单的情况下重新生成历史每日摘要
+// 滴答清单只能查询有限范围内的打卡与已完成任务，每次导出都会将获取到的数据合并进来
+type History struct {
+	Habits         []types.Habit                   `json:"habits"`
+	Checkins       map[string][]types.HabitCheckin `json:"checkins"`
+	TodoTasks      []types.Task                    `json:"todoTasks"` // 最近一次导出时的待办任务
+	CompletedTasks []types.Task                    `json:"completedTasks"`
+}
+
+// historyPath 历史数据文件路径
+func historyPath() string {
+	return filepath.Join(utils.GetStateDir(), "history.json")
+}
+
+// LoadHistory 读取历史数据，不存在时返回空的历史
+func LoadHistory() (*History, error) {
+	history := &History{Checkins: make(map[string][]types.HabitCheckin)}
+
+	content, err := os.ReadFile(historyPath())
+	if os.IsNotExist(err) {
+		return history, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取历史数据失败: %v", err)
+	}
+	if err := json.Unmarshal(content, history); err != nil {
+		return nil, fmt.Errorf("解析历史数据失败: %v", err)
+	}
+	if history.Checkins == nil {
+		history.Checkins = make(map[string][]types.HabitCheckin)
+	}
+	return history, nil
+}
+
+// save 保存历史数据
+func (h *History) save() error {
+	content, err := json.Marshal(h)
+	if err != nil {
+		return fmt.Errorf("序列化历史数据失败: %v", err)
+	}
+	if _, err := utils.WriteFile(historyPath(), content, 0644); err != nil {
+		return fmt.Errorf("写入历史数据失败: %v", err)
+	}
+	return nil
+}
+
+// UpdateHistory 将本次获取的习惯、打卡记录与任务合并到历史数据中
+// 习惯与已完成任务按 ID 合并，打卡记录按日期戳合并，获取失败时为空的数据不会覆盖已有记录
+func (e *Dida365Exporter) UpdateHistory() error {
+	history, err := LoadHistory()
+	if err != nil {
+		return err
+	}
+
+	habits := make(map[string]types.Habit)
+	for _, habit := range history.Habits {
+		habits[derefString(habit.ID)] = habit
+	}
+	for _, habit := range e.habits {
+		habits[derefString(habit.ID)] = habit
+	}
+	history.Habits = history.Habits[:0]
+	for _, habit := range habits {
+		history.Habits = append(history.Habits, habit)
+	}
+	sort.Slice(history.Habits, func(i, j int) bool {
+		return derefInt(history.Habits[i].SortOrder) < derefInt(history.Habits[j].SortOrder)
+	})
+
+	if e.checkins != nil {
+		for habitID, checkins := range e.checkins.Checkins {
+			history.Checkins[habitID] = mergeCheckins(history.Checkins[habitID], checkins)
+		}
+	}
+
+	if len(e.todoTasks) > 0 {
+		history.TodoTasks = e.todoTasks
+	}
+
+	completed := make(map[string]types.Task)
+	for _, task := range history.CompletedTasks {
+		completed[derefString(task.ID)] = task
+	}
+	for _, task := range e.completedTasks {
+		completed[derefString(task.ID)] = task
+	}
+	// 已重新打开的任务不再属于已完成任务
+	for _, task := range e.todoTasks {
+		delete(completed, derefString(task.ID))
+	}
+	history.CompletedTasks = history.CompletedTasks[:0]
+	for _, task := range completed {
+		history.CompletedTasks = append(history.CompletedTasks, task)
+	}
+	sort.Slice(history.CompletedTasks, func(i, j int) bool {
+		return derefString(history.CompletedTasks[i].CompletedTime) < derefString(history.CompletedTasks[j].CompletedTime)
+	})
+
+	return history.save()
+}
+
+// mergeCheckins 按日期戳合并打卡记录，同一天以新记录为准
+func mergeCheckins(existing, updates []types.HabitCheckin) []types.HabitCheckin {
+	byStamp := make(map[int]types.HabitCheckin)
+	for _, checkin := range existing {
+		if checkin.CheckinStamp != nil {
+			byStamp[*checkin.CheckinStamp] = checkin
+		}
+	}
+	for _, checkin := range updates {
+		if checkin.CheckinStamp != nil {
+			byStamp[*checkin.CheckinStamp] = checkin
+		}
+	}
+
+	merged := make([]types.HabitCheckin, 0, len(byStamp))
+	for _, checkin := range byStamp {
+		merged = append(merged, checkin)
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		return *merged[i].CheckinStamp < *merged[j].CheckinStamp
+	})
+	return merged
+}
@@ -0,0 +1,332 @@
+package exporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"exporter-to-obsidian/internal/state"
+	"exporter-to-obsidian/internal/utils"
+)
+
+// 导出文件的类型
+const (
+	kindTask   = "task"
+	kindNote   = "note"
+	kindColumn = "column"
+	kindTag    = "tag"
+	kindHabit  = "habit"
+)
+
+// 上游已删除文件的处理策略
+const (
+	policyDelete  = "delete"  // 直接删除
+	policyArchive = "archive" // 移动到归档目录
+	policyMark    = "mark"    // 在 Front Matter 中标记 status: deleted
+)
+
+// fileKinds 在状态文件中记录、上游删除后需要清理的文件类型
+var fileKinds = map[string]bool{
+	kindTask:   true,
+	kindNote:   true,
+	kindColumn: true,
+	kindTag:    true,
+	kindHabit:  true,
+}
+
+// TaskChecker 查询单个任务的当前状态，用于确认本次数据中缺失的任务是否已被删除
+type TaskChecker interface {
+	TaskStatus(projectID, taskID string) (status int, exists bool, err error)
+}
+
+// SetTaskChecker 设置任务查询，未设置时本次数据中缺失的任务文件只在同步结果明确删除时处理
+func (e *Dida365Exporter) SetTaskChecker(checker TaskChecker) {
+	e.taskChecker = checker
+}
+
+// manifestEntry 旧版本文件清单中的单个文件记录
+type manifestEntry struct {
+	Kind      string `json:"kind"`
+	ID        string `json:"id"`
+	ProjectID string `json:"projectId,omitempty"`
+	Status    int    `json:"status"`
+}
+
+// manifestPath 旧版本的文件清单路径，内容导入状态文件后删除
+func manifestPath() string {
+	return filepath.Join(utils.GetStateDir(), "manifest.json")
+}
+
+// loadManifest 读取旧版本的文件清单，键为相对输出目录的路径，不存在时返回 nil
+func loadManifest(path string) (map[string]manifestEntry, error) {
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取文件清单失败: %v", err)
+	}
+
+	var manifest struct {
+		Entries map[string]manifestEntry `json:"entries"`
+	}
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return nil, fmt.Errorf("解析文件清单失败: %v", err)
+	}
+	if manifest.Entries == nil {
+		manifest.Entries = make(map[string]manifestEntry)
+	}
+	return manifest.Entries, nil
+}
+
+// deletedFilePolicy 上游已删除文件的处理策略
+func deletedFilePolicy() string {
+	return utils.GetEnvOrDefault("DELETED_FILE_POLICY", policyArchive)
+}
+
+// track 在状态文件中记录本次导出拥有的文件
+// 输出路径变化时（如习惯改名），按删除策略处理旧路径的文件，并清除上次记录的版本与哈希
+func (e *Dida365Exporter) track(kind, id, parent string, status int, path string) {
+	key := state.Key(stateSource, kind, id)
+	rel := relativePath(e.outputDir, path)
+
+	entity, ok := e.state.Entity(key)
+	if ok && entity.Path != rel {
+		// 旧路径已被本次导出的其他文件使用时不做处理
+		if _, owned := e.owned[entity.Path]; entity.Path != "" && !owned {
+			if err := e.removeStaleFile(entity.Path, deletedFilePolicy()); err != nil {
+				fmt.Printf("处理旧文件失败 %s: %v\n", entity.Path, err)
+			}
+		}
+		entity = state.Entity{}
+	}
+	entity.Path = rel
+	entity.Parent = parent
+	entity.Status = status
+	e.state.SetEntity(key, entity)
+	e.owned[rel] = key
+}
+
+// importManifest 将旧版本文件清单中的记录导入状态文件，保存状态后删除清单文件
+// 状态文件中还没有文件记录时，根据已有文件生成记录，使历史遗留文件也能被清理
+func (e *Dida365Exporter) importManifest() {
+	path := manifestPath()
+	entries, err := loadManifest(path)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+	if entries != nil {
+		e.legacyFiles = append(e.legacyFiles, path)
+	} else if len(e.fileEntities()) == 0 {
+		entries = e.seedManifest()
+	}
+
+	for rel, entry := range entries {
+		key := state.Key(stateSource, entry.Kind, entry.ID)
+		entity, ok := e.state.Entity(key)
+		if ok && entity.Path != rel {
+			entity = state.Entity{}
+		}
+		entity.Path = rel
+		entity.Parent = entry.ProjectID
+		entity.Status = entry.Status
+		e.state.SetEntity(key, entity)
+	}
+}
+
+// seedManifest 根据已有文件生成文件记录
+func (e *Dida365Exporter) seedManifest() map[string]manifestEntry {
+	entries := make(map[string]manifestEntry)
+
+	dirs := map[string]string{
+		kindTask:   e.tasksDir,
+		kindNote:   e.notesDir,
+		kindColumn: e.columnsDir,
+		kindTag:    e.tagsDir,
+	}
+	for kind, dir := range dirs {
+		matches, _ := filepath.Glob(filepath.Join(dir, "*.md"))
+		for _, path := range matches {
+			content, err := os.ReadFile(path)
+			if err != nil {
+				continue
+			}
+			frontMatter, _, err := utils.ParseFrontMatter(string(content))
+			if err != nil {
+				continue
+			}
+			status, _ := strconv.Atoi(frontMatter.GetString("status"))
+			entries[relativePath(e.outputDir, path)] = manifestEntry{
+				Kind:      kind,
+				ID:        strings.TrimSuffix(filepath.Base(path), ".md"),
+				ProjectID: frontMatter.GetString("project_id"),
+				Status:    status,
+			}
+		}
+	}
+	return entries
+}
+
+// fileEntities 状态文件中滴答清单导出文件的记录，键为对象记录的键
+func (e *Dida365Exporter) fileEntities() map[string]state.Entity {
+	entities := e.state.Entities(stateSource + "/")
+	for key, entity := range entities {
+		if kind, _ := splitEntityKey(key); !fileKinds[kind] || entity.Path == "" {
+			delete(entities, key)
+		}
+	}
+	return entities
+}
+
+// splitEntityKey 从 dida365/<类型>/<ID> 形式的键中取出类型与ID，标签名称中可能包含 "/"
+func splitEntityKey(key string) (string, string) {
+	parts := strings.SplitN(key, "/", 3)
+	if len(parts) < 3 {
+		return "", ""
+	}
+	return parts[1], parts[2]
+}
+
+// CleanupStaleFiles 处理上游已不存在的任务、笔记、分组、标签和习惯文件
+// 状态文件中记录过、但本次导出没有生成的文件按 DELETED_FILE_POLICY 处理，并删除其记录；
+// 任务文件只在同步结果明确删除，或通过 TaskChecker 确认任务已不存在时处理
+// deletedIDs 为同步返回的已删除任务ID；complete 为 false 时仅处理这些明确删除的任务，
+// 避免因部分数据获取失败而误删文件
+func (e *Dida365Exporter) CleanupStaleFiles(deletedIDs []string, complete bool) error {
+	deleted := make(map[string]bool)
+	for _, id := range deletedIDs {
+		deleted[id] = true
+	}
+
+	// 本次导出涉及的项目，用于判断分组是否仍然存在
+	projectColumns := make(map[string]bool)
+	fetchedProjects := make(map[string]bool)
+	for _, project := range e.projects {
+		if project.Columns != nil {
+			fetchedProjects[project.ID] = true
+		}
+	}
+	for _, column := range e.all_columns {
+		if column.ID != nil {
+			projectColumns[*column.ID] = true
+		}
+	}
+
+	owned := make(map[string]bool)
+	for _, key := range e.owned {
+		owned[key] = true
+	}
+
+	policy := deletedFilePolicy()
+	removed := 0
+	for key, entity := range e.fileEntities() {
+		if owned[key] {
+			continue
+		}
+
+		kind, id := splitEntityKey(key)
+		stale := deleted[id]
+		if !stale && complete {
+			switch kind {
+			case kindTask:
+				// 任务完成时间超出本次获取的范围时同样不在数据中，逐个查询确认已被删除后才处理
+				stale = e.taskRemoved(key, id, entity)
+			case kindNote, kindTag:
+				stale = true
+			case kindHabit:
+				// 已归档的习惯不会导出，同样视为已删除；习惯列表获取失败时保留
+				stale = e.habits != nil
+			case kindColumn:
+				stale = fetchedProjects[entity.Parent] && !projectColumns[id]
+			}
+		}
+		if !stale {
+			continue
+		}
+
+		// 文件已被本次导出的其他对象使用时只删除记录
+		if _, ok := e.owned[entity.Path]; !ok {
+			if err := e.removeStaleFile(entity.Path, policy); err != nil {
+				fmt.Printf("处理已删除文件失败 %s: %v\n", entity.Path, err)
+				continue
+			}
+			removed++
+		}
+		e.state.DeleteEntity(key)
+	}
+
+	if removed > 0 {
+		fmt.Printf("已处理 %d 个上游已删除的文件（策略：%s）\n", removed, policy)
+	}
+	return nil
+}
+
+// taskRemoved 查询本次数据中缺失的任务，确认已被删除、移入回收站或放弃时返回 true
+// 上次导出时已完成的任务直接保留；查询到任务已完成时更新记录中的状态，之后不再查询；
+// 未设置任务查询或查询失败时保留文件
+func (e *Dida365Exporter) taskRemoved(key, id string, entity state.Entity) bool {
+	if entity.Status == 2 || e.taskChecker == nil {
+		return false
+	}
+	status, exists, err := e.taskChecker.TaskStatus(entity.Parent, id)
+	if err != nil {
+		fmt.Printf("查询任务 %s 失败，保留文件: %v\n", id, err)
+		return false
+	}
+	if !exists || status < 0 {
+		return true
+	}
+	if status != entity.Status {
+		entity.Status = status
+		e.state.SetEntity(key, entity)
+	}
+	return false
+}
+
+// removeStaleFile 按策略处理单个已删除文件
+func (e *Dida365Exporter) removeStaleFile(rel, policy string) error {
+	path := filepath.Join(e.outputDir, filepath.FromSlash(rel))
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+
+	switch policy {
+	case policyDelete:
+		return os.Remove(path)
+	case policyMark:
+		return markFileDeleted(path)
+	case policyArchive:
+		archiveDir := filepath.Join(e.outputDir, utils.GetEnvOrDefault("ARCHIVE_DIR", "Archive"))
+		target := filepath.Join(archiveDir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		return os.Rename(path, target)
+	default:
+		return fmt.Errorf("未知的删除策略: %s", policy)
+	}
+}
+
+// markFileDeleted 将文件 Front Matter 中的 status 标记为 deleted
+func markFileDeleted(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	frontMatter, body, err := utils.ParseFrontMatter(string(content))
+	if err != nil {
+		return err
+	}
+	if len(frontMatter.Keys()) == 0 {
+		return fmt.Errorf("文件缺少 Front Matter")
+	}
+	frontMatter.Set("status", "deleted")
+
+	_, err = utils.WriteFile(path, []byte(frontMatter.String()+body), 0644)
+	return err
+}
@@ -8,17 +8,31 @@ import (
 	"strings"
 	"time"
 
+	"exporter-to-obsidian/internal/state"
 	"exporter-to-obsidian/internal/types"
 	"exporter-to-obsidian/internal/utils"
 )
 
+// MemosResourceFetcher 下载Memos附件，返回内容与 Content-Type
+type MemosResourceFetcher interface {
+	DownloadResource(resource types.MemosResource) ([]byte, string, error)
+}
+
 // MemosExporter Memos导出器
 type MemosExporter struct {
-	records   []types.MemosRecord
-	outputDir string
-	memosDir  string
+	records     []types.MemosRecord
+	outputDir   string
+	memosDir    string
+	renderer    *Renderer
+	dailyNote   *DailyNote
+	fetcher     MemosResourceFetcher
+	attachments *AttachmentStore
+	state       *state.Store // 每日摘要与汇总文件的导出记录
 }
 
+// memosSource 状态文件中Memos的数据源名称
+const memosSource = "memos"
+
 // NewMemosExporter 创建新的Memos导出器
 func NewMemosExporter(records []types.MemosRecord, outputDir string) *MemosExporter {
 	if outputDir == "" {
@@ -31,6 +45,9 @@ func NewMemosExporter(records []types.MemosRecord, outputDir string) *MemosExpor
 		records:   records,
 		outputDir: outputDir,
 		memosDir:  memosDir,
+		renderer:  NewRenderer(outputDir),
+		dailyNote: NewDailyNote(outputDir),
+		state:     state.Open(),
 	}
 
 	// 确保目录存在
@@ -47,100 +64,275 @@ func NewMemosExporter(records []types.MemosRecord, outputDir string) *MemosExpor
 	return exporter
 }
 
-// ExportDailyMemos 导出每日Memos摘要
-func (e *MemosExporter) ExportDailyMemos(date time.Time) error {
-	// 设置日期范围
-	startDate := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.Local)
-	endDate := startDate.Add(24*time.Hour - time.Second)
+// SetResourceFetcher 设置附件下载器，设置后附件会被下载到 MEMOS_ATTACHMENTS_DIR（默认 Memos/Attachments）
+func (e *MemosExporter) SetResourceFetcher(fetcher MemosResourceFetcher) {
+	e.fetcher = fetcher
+	dir := utils.GetEnvOrDefault("MEMOS_ATTACHMENTS_DIR", filepath.Join(utils.GetEnvOrDefault("MEMOS_DIR", "Memos"), "Attachments"))
+	e.attachments = NewAttachmentStore(e.outputDir, dir, "memos")
+}
 
-	// 获取当日的Memos记录
-	dailyRecords := e.getRecordsInDateRange(startDate, endDate)
+// ExportRange 导出指定日期范围内每一天的Memos摘要，没有记录的日期会被跳过
+// MEMOS_ROLLUPS 包含 weekly、monthly 时同时更新范围所在周、月的汇总
+func (e *MemosExporter) ExportRange(from, to time.Time) error {
+	from = utils.StartOfDay(from)
+	to = utils.StartOfDay(to)
+
+	groups := e.groupRecordsByDate()
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		records := groups[day.Format("2006-01-02")]
+		if len(records) == 0 {
+			continue
+		}
+		if err := e.writeDailyMemos(day, records); err != nil {
+			return err
+		}
+	}
 
+	rollups := make(map[string]bool)
+	for _, name := range strings.Split(os.Getenv("MEMOS_ROLLUPS"), ",") {
+		rollups[strings.ToLower(strings.TrimSpace(name))] = true
+	}
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		if rollups["weekly"] && (day.Equal(from) || day.Weekday() == time.Monday) {
+			if err := e.ExportWeeklyMemos(day); err != nil {
+				return err
+			}
+		}
+		if rollups["monthly"] && (day.Equal(from) || day.Day() == 1) {
+			if err := e.ExportMonthlyMemos(day); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ExportDailyMemos 导出每日Memos摘要，当日没有记录时不生成文件
+func (e *MemosExporter) ExportDailyMemos(date time.Time) error {
+	date = utils.StartOfDay(date)
+	records := e.groupRecordsByDate()[date.Format("2006-01-02")]
+	if len(records) == 0 {
+		fmt.Printf("%s 没有Memos记录\n", date.Format("2006-01-02"))
+		return nil
+	}
+	return e.writeDailyMemos(date, records)
+}
+
+// writeDailyMemos 写入单日的Memos摘要
+func (e *MemosExporter) writeDailyMemos(date time.Time, dailyRecords []types.MemosRecord) error {
 	// 创建文件名
 	filename := fmt.Sprintf("%s-Memos.md", date.Format("2006-01-02"))
 	filepath := filepath.Join(e.memosDir, filename)
 
-	// 准备文件内容
-	content := utils.GetFrontMatter([]string{"noyaml"}, "")
-	// content += fmt.Sprintf("# %s Memos摘要\n\n", date.Format("2006-01-02"))
-
-	if len(dailyRecords) > 0 {
-		// 按时间排序（最新的在前）
-		sort.Slice(dailyRecords, func(i, j int) bool {
-			timeI := int64(0)
-			if dailyRecords[i].CreatedTs != nil {
-				timeI = *dailyRecords[i].CreatedTs
-			}
-			timeJ := int64(0)
-			if dailyRecords[j].CreatedTs != nil {
-				timeJ = *dailyRecords[j].CreatedTs
-			}
-			return timeI > timeJ
+	// 按时间排序（最新的在前）
+	sort.Slice(dailyRecords, func(i, j int) bool {
+		timeI := int64(0)
+		if dailyRecords[i].CreatedTs != nil {
+			timeI = *dailyRecords[i].CreatedTs
+		}
+		timeJ := int64(0)
+		if dailyRecords[j].CreatedTs != nil {
+			timeJ = *dailyRecords[j].CreatedTs
+		}
+		return timeI > timeJ
+	})
+
+	records := make([]MemosRecordData, 0, len(dailyRecords))
+	for _, record := range dailyRecords {
+		records = append(records, MemosRecordData{
+			MemosRecord: record,
+			Attachments: e.downloadAttachments(record),
 		})
+	}
+	if e.attachments != nil {
+		if err := e.attachments.Save(); err != nil {
+			fmt.Printf("%v\n", err)
+		}
+	}
+
+	// 准备文件内容
+	content, err := e.renderer.Render(tmplMemosDaily, MemosDailyData{
+		Date:        date,
+		FrontMatter: displayFrontMatter("noyaml"),
+		Records:     records,
+	})
+	if err != nil {
+		return err
+	}
 
-		for _, record := range dailyRecords {
-			content += e.formatMemosRecord(record)
+	// 写入用户的每日笔记
+	if mergeDailyNote() {
+		if err := e.dailyNote.Merge(date, sectionMemos, content); err != nil {
+			return err
 		}
-	} else {
-		return fmt.Errorf("今日没有Memos记录")
+	}
+	if !writeSeparateDaily() {
+		return nil
 	}
 
 	// 写入文件
-	if err := os.WriteFile(filepath, []byte(content), 0644); err != nil {
+	written, err := utils.WriteFile(filepath, []byte(content), 0644)
+	if err != nil {
 		return fmt.Errorf("写入每日Memos摘要失败: %v", err)
 	}
 
-	fmt.Printf("已创建每日Memos摘要：%s\n", filename)
+	if written {
+		fmt.Printf("已创建每日Memos摘要：%s\n", filename)
+	}
+	e.record(state.Key(memosSource, "daily", date.Format("2006-01-02")), memosVersion(dailyRecords), filepath, content)
 	return nil
 }
 
-// getRecordsInDateRange 获取指定日期范围内的Memos记录
-func (e *MemosExporter) getRecordsInDateRange(startDate, endDate time.Time) []types.MemosRecord {
-	var records []types.MemosRecord
+// ExportWeeklyMemos 导出每周Memos汇总，嵌入本周已导出的每日摘要
+func (e *MemosExporter) ExportWeeklyMemos(date time.Time) error {
+	// 获取周的开始和结束日期（周一到周日）
+	weekday := int(date.Weekday())
+	if weekday == 0 {
+		weekday = 7 // 将周日从0改为7
+	}
+	startOfWeek := utils.StartOfDay(date).AddDate(0, 0, -(weekday - 1))
+	endOfWeek := startOfWeek.AddDate(0, 0, 6)
 
-	for _, record := range e.records {
-		if record.CreatedTs != nil {
-			recordTime := time.Unix(*record.CreatedTs, 0)
-			if !recordTime.Before(startDate) && !recordTime.After(endDate) {
-				records = append(records, record)
+	year, week := date.ISOWeek()
+	filename := fmt.Sprintf("%d-W%d-Memos.md", year, week)
+	return e.writePeriodMemos(tmplMemosWeekly, state.Key(memosSource, "weekly", fmt.Sprintf("%d-W%d", year, week)), filepath.Join(e.memosDir, "Weekly", filename), MemosPeriodData{
+		Start: startOfWeek,
+		End:   endOfWeek,
+		Year:  year,
+		Week:  week,
+	})
+}
+
+// ExportMonthlyMemos 导出每月Memos汇总，嵌入本月已导出的每日摘要
+func (e *MemosExporter) ExportMonthlyMemos(date time.Time) error {
+	firstDay := time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, utils.Location())
+	lastDay := firstDay.AddDate(0, 1, -1)
+
+	year, week := firstDay.ISOWeek()
+	filename := fmt.Sprintf("%s-Memos.md", date.Format("2006-01"))
+	return e.writePeriodMemos(tmplMemosMonthly, state.Key(memosSource, "monthly", date.Format("2006-01")), filepath.Join(e.memosDir, "Monthly", filename), MemosPeriodData{
+		Start: firstDay,
+		End:   lastDay,
+		Year:  year,
+		Week:  week,
+	})
+}
+
+// writePeriodMemos 收集周期内已存在的每日摘要并写入汇总文件，key 为汇总在状态文件中的记录键
+func (e *MemosExporter) writePeriodMemos(name, key, path string, data MemosPeriodData) error {
+	for day := data.Start; !day.After(data.End); day = day.AddDate(0, 0, 1) {
+		file := fmt.Sprintf("%s-Memos", day.Format("2006-01-02"))
+		if _, err := os.Stat(filepath.Join(e.memosDir, file+".md")); err == nil {
+			data.Days = append(data.Days, MemosDayLink{Date: day, File: file})
+			continue
+		}
+		// 只写入每日笔记时链接到笔记中的Memos区域
+		if mergeDailyNote() {
+			path := e.dailyNote.Path(day)
+			if content, err := os.ReadFile(path); err == nil {
+				if _, ok := managedSection(string(content), sectionMemos); ok {
+					data.Days = append(data.Days, MemosDayLink{Date: day, File: strings.TrimSuffix(relativePath(e.outputDir, path), ".md")})
+				}
 			}
 		}
 	}
+	data.FrontMatter = displayFrontMatter("noyaml")
+
+	content, err := e.renderer.Render(name, data)
+	if err != nil {
+		return err
+	}
+
+	written, err := utils.WriteFile(path, []byte(content), 0644)
+	if err != nil {
+		return fmt.Errorf("写入Memos汇总失败: %v", err)
+	}
 
-	return records
+	if written {
+		fmt.Printf("已创建Memos汇总：%s\n", filepath.Base(path))
+	}
+	e.record(key, "", path, content)
+	return nil
 }
 
-// formatMemosRecord 格式化Memos记录
-func (e *MemosExporter) formatMemosRecord(record types.MemosRecord) string {
-	var content strings.Builder
+// record 在状态文件中记录导出文件对应的记录版本、路径与内容的哈希
+func (e *MemosExporter) record(key, version, path, content string) {
+	e.state.SetEntity(key, state.Entity{
+		Version: version,
+		Path:    relativePath(e.outputDir, path),
+		Hash:    state.Hash(content),
+	})
+}
 
-	// 添加时间戳
-	if record.CreatedTs != nil {
-		recordTime := time.Unix(*record.CreatedTs, 0)
-		content.WriteString(fmt.Sprintf("#### **%s**\n\n", recordTime.Format("15:04:05")))
+// memosVersion 一组记录的版本，由记录数与最近的更新时间组成
+func memosVersion(records []types.MemosRecord) string {
+	var latest int64
+	for _, record := range records {
+		if record.UpdatedTs != nil && *record.UpdatedTs > latest {
+			latest = *record.UpdatedTs
+		}
 	}
+	return fmt.Sprintf("%d@%d", len(records), latest)
+}
 
-	// 添加内容
-	if record.Content != nil {
-		content.WriteString(fmt.Sprintf("%s", *record.Content))
-		content.WriteString("\n\n")
+// SaveState 保存本次导出的文件记录
+func (e *MemosExporter) SaveState() error {
+	return e.state.Save()
+}
+
+// groupRecordsByDate 按本地日期（YYYY-MM-DD）对记录分组
+func (e *MemosExporter) groupRecordsByDate() map[string][]types.MemosRecord {
+	groups := make(map[string][]types.MemosRecord)
+	for _, record := range e.records {
+		if record.CreatedTs == nil {
+			continue
+		}
+		date := time.Unix(*record.CreatedTs, 0).In(utils.Location()).Format("2006-01-02")
+		groups[date] = append(groups[date], record)
 	}
+	return groups
+}
 
-	// 添加资源列表
-	if len(record.ResourceList) > 0 {
-		content.WriteString("**附件：**\n")
-		for _, resource := range record.ResourceList {
-			if resource.Filename != nil {
-				content.WriteString(fmt.Sprintf("- %s", *resource.Filename))
-				if resource.ExternalLink != nil {
-					content.WriteString(fmt.Sprintf(" ([链接](%s))", *resource.ExternalLink))
-				}
-				content.WriteString("\n")
+// downloadAttachments 下载记录的附件，未设置下载器或下载失败时保留原始链接
+func (e *MemosExporter) downloadAttachments(record types.MemosRecord) []MemosAttachment {
+	var attachments []MemosAttachment
+	for _, resource := range record.ResourceList {
+		attachment := MemosAttachment{
+			Resource: resource,
+			Filename: derefString(resource.Filename),
+		}
+		if resource.ExternalLink != nil {
+			attachment.ExternalLink = *resource.ExternalLink
+		}
+
+		if e.fetcher != nil {
+			key := memosResourceKey(resource)
+			path, err := e.attachments.Store(key, attachment.Filename, func() ([]byte, string, error) {
+				return e.fetcher.DownloadResource(resource)
+			})
+			if err != nil {
+				fmt.Printf("下载附件 %s 失败: %v\n", attachment.Filename, err)
+			} else {
+				attachment.Path = path
+				attachment.Image = isImage(derefString(resource.Type), path)
 			}
 		}
-		content.WriteString("\n")
+
+		attachments = append(attachments, attachment)
 	}
+	return attachments
+}
 
-	content.WriteString("---\n\n")
-	return content.String()
+// memosResourceKey 附件的唯一标识
+func memosResourceKey(resource types.MemosResource) string {
+	switch {
+	case resource.Name != nil && *resource.Name != "":
+		return *resource.Name
+	case resource.UID != nil && *resource.UID != "":
+		return "uid:" + *resource.UID
+	case resource.ID != nil:
+		return fmt.Sprintf("id:%d", *resource.ID)
+	default:
+		return "link:" + derefString(resource.ExternalLink)
+	}
 }
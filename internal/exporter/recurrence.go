@@ -0,0 +1,95 @@
+package exporter
+
+import (
+	"sort"
+	"time"
+
+	"exporter-to-obsidian/internal/rrule"
+	"exporter-to-obsidian/internal/types"
+	"exporter-to-obsidian/internal/utils"
+)
+
+// taskRule 解析任务的重复规则，没有规则或无法解析时返回 nil
+func taskRule(task types.Task) *rrule.Rule {
+	if task.RepeatFlag == nil || *task.RepeatFlag == "" {
+		return nil
+	}
+	rule, err := rrule.Parse(*task.RepeatFlag, utils.Location())
+	if err != nil {
+		return nil
+	}
+	return rule
+}
+
+// taskRecurrence 任务重复规则的中文描述，如 "每周的周一、周三"
+func taskRecurrence(task types.Task) string {
+	if rule := taskRule(task); rule != nil {
+		return rule.String()
+	}
+	return ""
+}
+
+// recurringOccurrences 展开待办重复任务在 [start, end] 内的后续重复
+// 重复序列以 repeatFirstDate 为起点，COUNT 与 INTERVAL、BYDAY 的周期从第一次开始计算，只返回任务当前这一次之后的重复
+// 每次重复是任务的副本，开始与截止时间按重复日期平移；任务当前的这一次由 taskInRange 处理，不在结果中
+func (e *Dida365Exporter) recurringOccurrences(start, end time.Time) []types.Task {
+	var occurrences []types.Task
+	for _, task := range e.todoTasks {
+		rule := taskRule(task)
+		if rule == nil || derefInt(task.Status) != 0 {
+			continue
+		}
+		current := taskStartDate(task)
+		if current == nil {
+			current = taskDueDate(task)
+		}
+		if current == nil {
+			continue
+		}
+
+		var exdates []time.Time
+		for _, value := range task.ExDate {
+			if t := utils.ParseTaskDate(value, derefString(task.TimeZone), task.IsAllDay != nil && *task.IsAllDay); t != nil {
+				exdates = append(exdates, *t)
+			}
+		}
+
+		due := taskDueDate(task)
+		for _, t := range rule.Between(seriesStart(task, *current), start, end, exdates) {
+			if !t.After(*current) {
+				continue
+			}
+			occurrence := task
+			occurrence.Occurrence = true
+			startDate := t
+			occurrence.ProcessedStartDate = &startDate
+			if due != nil {
+				dueDate := due.Add(t.Sub(*current))
+				occurrence.ProcessedDueDate = &dueDate
+			}
+			occurrences = append(occurrences, occurrence)
+		}
+	}
+
+	sort.SliceStable(occurrences, func(i, j int) bool {
+		return occurrences[i].ProcessedStartDate.Before(*occurrences[j].ProcessedStartDate)
+	})
+	return occurrences
+}
+
+// seriesStart 重复序列的第一次，取 repeatFirstDate 的日期与任务当前这一次的时刻
+// 没有 repeatFirstDate 或其晚于当前这一次时，以当前这一次为起点
+func seriesStart(task types.Task, current time.Time) time.Time {
+	if task.RepeatFirstDate == nil {
+		return current
+	}
+	first := utils.ParseTaskDate(*task.RepeatFirstDate, derefString(task.TimeZone), task.IsAllDay != nil && *task.IsAllDay)
+	if first == nil {
+		return current
+	}
+	anchor := time.Date(first.Year(), first.Month(), first.Day(), current.Hour(), current.Minute(), current.Second(), 0, current.Location())
+	if anchor.After(current) {
+		return current
+	}
+	return anchor
+}
@@ -0,0 +1,175 @@
+package exporter
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"exporter-to-obsidian/internal/types"
+	"exporter-to-obsidian/internal/utils"
+)
+
+// SetHabits 设置习惯及打卡记录，用于每周、每月摘要中的打卡统计
+// habits 为 nil 表示习惯列表获取失败，此时不会清理习惯文件
+func (e *Dida365Exporter) SetHabits(habits []types.Habit, checkins *types.HabitCheckinsResponse) {
+	e.habits = habits
+	e.checkins = checkins
+}
+
+// summaryDataview 是否在每周、每月摘要末尾附加 Dataview 查询（SUMMARY_DATAVIEW）
+func summaryDataview() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv("SUMMARY_DATAVIEW"))
+	return enabled
+}
+
+// periodSummary 生成周期内的任务统计与习惯打卡统计，groups 为预先划分好的分组
+// 重复任务的后续重复列在对应的分组中，但在周期与分组的任务数中每个任务只计一次
+func (e *Dida365Exporter) periodSummary(data *PeriodSummaryData, groups []TaskGroup, group func(task types.Task) int) {
+	tasks := e.getTasksInDateRange(data.Start, data.End)
+	sortTasks(tasks)
+
+	counted := make(map[string]bool)
+	groupCounted := make([]map[string]bool, len(groups))
+	for i := range groups {
+		groupCounted[i] = make(map[string]bool)
+	}
+
+	for _, task := range tasks {
+		id := derefString(task.ID)
+		done := derefInt(task.Status) == 2
+		if !counted[id] {
+			counted[id] = true
+			data.Total++
+			if done {
+				data.Done++
+			} else {
+				data.Todo++
+			}
+		}
+
+		i := group(task)
+		if i < 0 {
+			data.Others = append(data.Others, task)
+			continue
+		}
+		groups[i].Tasks = append(groups[i].Tasks, task)
+		if !groupCounted[i][id] {
+			groupCounted[i][id] = true
+			groups[i].Total++
+			if done {
+				groups[i].Done++
+			}
+		}
+	}
+
+	data.Groups = groups
+	data.Habits = e.habitTotals(data.Start, data.End)
+	data.Dataview = summaryDataview()
+}
+
+// weekGroups 将一周划分为每天一个分组
+func weekGroups(startOfWeek time.Time) []TaskGroup {
+	groups := make([]TaskGroup, 0, 7)
+	for i := 0; i < 7; i++ {
+		day := startOfWeek.AddDate(0, 0, i)
+		groups = append(groups, TaskGroup{
+			Title: fmt.Sprintf("%s %s", day.Format("01-02"), weekdayName(day)),
+			Start: day,
+			End:   day.Add(24*time.Hour - time.Second),
+		})
+	}
+	return groups
+}
+
+// monthGroups 将一个月按周（周一开始）划分为分组，首尾两周只包含当月的日期
+func monthGroups(firstDay, lastDay time.Time) []TaskGroup {
+	var groups []TaskGroup
+	for start := firstDay; !start.After(lastDay); {
+		end := utils.StartOfWeek(start).AddDate(0, 0, 6)
+		if end.After(lastDay) {
+			end = utils.StartOfDay(lastDay)
+		}
+		groups = append(groups, TaskGroup{
+			Title: fmt.Sprintf("第%d周 (%s ~ %s)", len(groups)+1, start.Format("01-02"), end.Format("01-02")),
+			Start: start,
+			End:   end.Add(24*time.Hour - time.Second),
+		})
+		start = end.AddDate(0, 0, 1)
+	}
+	return groups
+}
+
+// groupIndex 返回日期所在分组的序号，不在任何分组内时返回 -1
+func groupIndex(groups []TaskGroup, date string) int {
+	if date == "" {
+		return -1
+	}
+	t, err := time.ParseInLocation("2006-01-02", date, utils.Location())
+	if err != nil {
+		return -1
+	}
+	for i, group := range groups {
+		if !t.Before(group.Start) && !t.After(group.End) {
+			return i
+		}
+	}
+	return -1
+}
+
+// habitTotals 统计每个习惯在 [start, end] 内的打卡天数，天数只计算到今天为止
+func (e *Dida365Exporter) habitTotals(start, end time.Time) []HabitTotal {
+	if len(e.habits) == 0 {
+		return nil
+	}
+
+	last := utils.StartOfDay(end)
+	if today := utils.StartOfDay(utils.Now()); today.Before(last) {
+		last = today
+	}
+	days := 0
+	if !last.Before(utils.StartOfDay(start)) {
+		days = int(last.Sub(utils.StartOfDay(start)).Hours()/24+0.5) + 1
+	}
+	from, to := utils.DateStamp(start), utils.DateStamp(end)
+
+	totals := make([]HabitTotal, 0, len(e.habits))
+	for _, habit := range e.habits {
+		total := HabitTotal{Habit: habit, Days: days}
+		if e.checkins != nil && habit.ID != nil {
+			checked := make(map[int]bool)
+			for _, checkin := range e.checkins.Checkins[*habit.ID] {
+				if checkin.CheckinStamp == nil || checkin.Status == nil || *checkin.Status != 2 {
+					continue
+				}
+				if stamp := *checkin.CheckinStamp; stamp >= from && stamp <= to {
+					checked[stamp] = true
+				}
+			}
+			total.Checked = len(checked)
+		}
+		totals = append(totals, total)
+	}
+	return totals
+}
+
+// sortTasks 按开始或截止时间排序，同一时间按优先级从高到低
+func sortTasks(tasks []types.Task) {
+	taskTime := func(task types.Task) time.Time {
+		if t := taskDueDate(task); t != nil {
+			return *t
+		}
+		if t := taskStartDate(task); t != nil {
+			return *t
+		}
+		return time.Time{}
+	}
+	sort.SliceStable(tasks, func(i, j int) bool {
+		ti, tj := taskTime(tasks[i]), taskTime(tasks[j])
+		if !ti.Equal(tj) {
+			return ti.Before(tj)
+		}
+		return derefInt(tasks[i].Priority) > derefInt(tasks[j].Priority)
+	})
+}
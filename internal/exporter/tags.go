@@ -0,0 +1,229 @@
+package exporter
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"exporter-to-obsidian/internal/types"
+	"exporter-to-obsidian/internal/utils"
+)
+
+// TagLink 标签笔记中指向其他标签笔记的链接
+type TagLink struct {
+	File  string // 标签笔记文件名（不含扩展名）
+	Title string
+	Path  string // Obsidian 标签路径，如 parent/child
+}
+
+// TagNoteData 标签笔记模板数据
+type TagNoteData struct {
+	Tag         types.Tag
+	Title       string
+	Path        string // Obsidian 标签路径，如 parent/child
+	FrontMatter string
+	Parent      *TagLink
+	Children    []TagLink
+	TodoTasks   []types.Task
+	DoneTasks   []types.Task
+}
+
+// SetTags 设置滴答清单标签，用于生成嵌套标签与标签笔记
+// 任务中的标签名称不区分大小写，映射的键统一使用小写
+func (e *Dida365Exporter) SetTags(tags []types.Tag) {
+	e.tags = make(map[string]types.Tag)
+	for _, tag := range tags {
+		if tag.Name != nil && *tag.Name != "" {
+			e.tags[strings.ToLower(*tag.Name)] = tag
+		}
+	}
+}
+
+// ExportTags 为每个标签生成一篇笔记，列出该标签下的任务
+func (e *Dida365Exporter) ExportTags() error {
+	names := make([]string, 0, len(e.tags))
+	for name := range e.tags {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		oi, oj := derefInt(e.tags[names[i]].SortOrder), derefInt(e.tags[names[j]].SortOrder)
+		if oi != oj {
+			return oi < oj
+		}
+		return names[i] < names[j]
+	})
+
+	for _, key := range names {
+		if err := e.createTagMarkdown(e.tags[key]); err != nil {
+			fmt.Printf("创建标签文件失败: %v\n", err)
+			return fmt.Errorf("创建标签文件失败: %v", err)
+		}
+	}
+	return nil
+}
+
+// createTagMarkdown 为单个标签创建Markdown文件
+func (e *Dida365Exporter) createTagMarkdown(tag types.Tag) error {
+	name := derefString(tag.Name)
+	filename := fmt.Sprintf("%s.md", tagFileName(name))
+	filepath := filepath.Join(e.tagsDir, filename)
+	e.track(kindTag, name, "", 0, filepath)
+
+	data := TagNoteData{
+		Tag:   tag,
+		Title: tagTitle(tag),
+		Path:  e.tagPath(name),
+	}
+	if tag.Parent != nil {
+		parent := e.tagLink(*tag.Parent)
+		data.Parent = &parent
+	}
+	for _, child := range e.tags {
+		if child.Parent != nil && strings.EqualFold(*child.Parent, name) {
+			data.Children = append(data.Children, e.tagLink(derefString(child.Name)))
+		}
+	}
+	sort.Slice(data.Children, func(i, j int) bool {
+		return data.Children[i].Path < data.Children[j].Path
+	})
+
+	for _, task := range e.todoTasks {
+		if hasTag(task, name) {
+			data.TodoTasks = append(data.TodoTasks, task)
+		}
+	}
+	for _, task := range e.completedTasks {
+		if hasTag(task, name) {
+			data.DoneTasks = append(data.DoneTasks, task)
+		}
+	}
+	sort.SliceStable(data.TodoTasks, func(i, j int) bool {
+		return derefInt(data.TodoTasks[i].Priority) > derefInt(data.TodoTasks[j].Priority)
+	})
+	sort.SliceStable(data.DoneTasks, func(i, j int) bool {
+		return derefString(data.DoneTasks[i].CompletedTime) > derefString(data.DoneTasks[j].CompletedTime)
+	})
+
+	frontMatter := utils.NewFrontMatter()
+	frontMatter.Set("title", data.Title)
+	frontMatter.Set("tag_name", name)
+	frontMatter.Set("tag", data.Path)
+	if tag.Parent != nil {
+		frontMatter.Set("parent", *tag.Parent)
+	}
+	if tag.Color != nil {
+		frontMatter.Set("color", *tag.Color)
+	}
+	if tag.SortOrder != nil {
+		frontMatter.Set("sort_order", *tag.SortOrder)
+	}
+	if tag.SortType != nil {
+		frontMatter.Set("sort_type", *tag.SortType)
+	}
+	frontMatter.Set("todo_count", len(data.TodoTasks))
+	frontMatter.Set("done_count", len(data.DoneTasks))
+	frontMatter.SetDisplay("noyaml")
+	data.FrontMatter = frontMatter.String()
+
+	content, err := e.renderer.Render(tmplTag, data)
+	if err != nil {
+		return err
+	}
+
+	if _, err := utils.WriteFile(filepath, []byte(content), 0644); err != nil {
+		return fmt.Errorf("写入标签文件失败: %v", err)
+	}
+	return nil
+}
+
+// tagLink 构建指向标签笔记的链接
+func (e *Dida365Exporter) tagLink(name string) TagLink {
+	link := TagLink{File: tagFileName(name), Title: name, Path: e.tagPath(name)}
+	if tag, ok := e.tags[strings.ToLower(name)]; ok {
+		link.File = tagFileName(derefString(tag.Name))
+		link.Title = tagTitle(tag)
+	}
+	return link
+}
+
+// obsidianTags 将任务的标签名称转换为 Obsidian 标签路径，保持顺序并去重
+func (e *Dida365Exporter) obsidianTags(names []string) []string {
+	var tags []string
+	seen := make(map[string]bool)
+	for _, name := range names {
+		path := e.tagPath(name)
+		if path == "" || seen[path] {
+			continue
+		}
+		seen[path] = true
+		tags = append(tags, path)
+	}
+	return tags
+}
+
+// tagPath 按父标签逐级拼接出 parent/child 形式的 Obsidian 标签
+func (e *Dida365Exporter) tagPath(name string) string {
+	var segments []string
+	visited := make(map[string]bool)
+	for name != "" && !visited[strings.ToLower(name)] {
+		visited[strings.ToLower(name)] = true
+		tag, ok := e.tags[strings.ToLower(name)]
+		if !ok {
+			segments = append([]string{sanitizeTag(name)}, segments...)
+			break
+		}
+		segments = append([]string{sanitizeTag(tagTitle(tag))}, segments...)
+		name = derefString(tag.Parent)
+	}
+	return strings.Join(segments, "/")
+}
+
+// tagTitle 标签的显示名称，优先使用带大小写的 label
+func tagTitle(tag types.Tag) string {
+	if tag.Label != nil && *tag.Label != "" {
+		return *tag.Label
+	}
+	return derefString(tag.Name)
+}
+
+// sanitizeTag 将标签名称转换为 Obsidian 允许的标签字符，空格与标点替换为 -
+func sanitizeTag(name string) string {
+	name = strings.TrimPrefix(strings.TrimSpace(name), "#")
+	var b strings.Builder
+	numeric := true
+	for _, r := range name {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-':
+			b.WriteRune(r)
+		case unicode.IsSpace(r) || unicode.IsPunct(r) || unicode.IsControl(r):
+			b.WriteRune('-')
+		default:
+			b.WriteRune(r)
+		}
+		if !unicode.IsDigit(r) {
+			numeric = false
+		}
+	}
+	// Obsidian 不允许纯数字标签
+	if numeric && b.Len() > 0 {
+		return "_" + b.String()
+	}
+	return b.String()
+}
+
+// tagFileName 标签笔记的文件名，替换路径分隔符
+func tagFileName(name string) string {
+	return strings.NewReplacer("/", "-", "\\", "-").Replace(name)
+}
+
+// hasTag 判断任务是否带有指定标签
+func hasTag(task types.Task, name string) bool {
+	for _, tag := range task.Tags {
+		if strings.EqualFold(tag, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,311 @@
+package exporter
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"exporter-to-obsidian/internal/types"
+	"exporter-to-obsidian/internal/utils"
+)
+
+// 内置模板，与模板目录中的同名文件可互相替换
+//
+//go:embed templates/*.tmpl
+var builtinTemplates embed.FS
+
+// 模板名称
+const (
+	tmplTask         = "task.md.tmpl"
+	tmplNote         = "note.md.tmpl"
+	tmplColumn       = "column.md.tmpl"
+	tmplTasksInbox   = "tasks_inbox.md.tmpl"
+	tmplDaily        = "daily.md.tmpl"
+	tmplWeekly       = "weekly.md.tmpl"
+	tmplMonthly      = "monthly.md.tmpl"
+	tmplMemosDaily   = "memos_daily.md.tmpl"
+	tmplMemosWeekly  = "memos_weekly.md.tmpl"
+	tmplMemosMonthly = "memos_monthly.md.tmpl"
+	tmplTag          = "tag.md.tmpl"
+	tmplHabit        = "habit.md.tmpl"
+)
+
+// TaskNoteData 任务笔记模板数据
+type TaskNoteData struct {
+	Task        types.Task
+	FrontMatter string
+	Content     string // 转换图片与链接后的任务内容
+	Desc        string // 转换图片与链接后的任务描述
+}
+
+// ColumnNoteData 分组笔记模板数据
+type ColumnNoteData struct {
+	Column      types.Column
+	Project     *types.Project // 分组所属项目，找不到时为 nil
+	FrontMatter string
+}
+
+// ColumnTasks 分组及其下的任务
+type ColumnTasks struct {
+	Column types.Column
+	Tasks  []types.Task
+}
+
+// ProjectIndex 项目索引中的单个项目
+type ProjectIndex struct {
+	Project types.Project
+	Tasks   []types.Task
+	Columns []ColumnTasks
+}
+
+// TasksInboxData 项目索引模板数据
+type TasksInboxData struct {
+	FrontMatter string
+	Projects    []ProjectIndex
+}
+
+// HabitStatus 习惯在某一天的打卡状态
+type HabitStatus struct {
+	Habit    types.Habit
+	Due      bool // 按重复规则当天是否需要打卡
+	Checked  bool
+	DoneDate string
+	Value    float64 // 数值型习惯当天记录的数值
+}
+
+// DailySummaryData 每日摘要模板数据
+type DailySummaryData struct {
+	Date        time.Time
+	FrontMatter string
+	Habits      []HabitStatus
+	Tasks       []types.Task // 当日全部任务
+	TodoTasks   []types.Task
+	DoneTasks   []types.Task
+}
+
+// PeriodSummaryData 每周、每月摘要模板数据
+type PeriodSummaryData struct {
+	Start       time.Time
+	End         time.Time
+	Year        int
+	Week        int
+	FrontMatter string
+	Groups      []TaskGroup  // 每周摘要按天分组，每月摘要按周分组
+	Others      []types.Task // 无法归入某一天的任务，如跨越整个周期或已逾期的任务
+	Total       int
+	Todo        int
+	Done        int
+	Habits      []HabitTotal
+	Dataview    bool // 是否附加 Dataview 查询（SUMMARY_DATAVIEW）
+}
+
+// TaskGroup 周期摘要中的一组任务
+type TaskGroup struct {
+	Title string
+	Start time.Time
+	End   time.Time
+	Tasks []types.Task // 包含重复任务的后续重复
+	Total int          // 分组内的任务数，同一重复任务的多次重复只计一次
+	Done  int
+}
+
+// HabitTotal 习惯在周期内的打卡统计
+type HabitTotal struct {
+	Habit   types.Habit
+	Checked int // 已打卡天数
+	Days    int // 周期内截至今天的天数
+}
+
+// MemosAttachment Memos记录的附件
+type MemosAttachment struct {
+	Resource     types.MemosResource
+	Filename     string
+	ExternalLink string
+	Path         string // 下载到仓库后相对输出目录的路径，未下载时为空
+	Image        bool   // 是否为可嵌入显示的图片
+}
+
+// MemosRecordData 单条Memos记录及其附件
+type MemosRecordData struct {
+	types.MemosRecord
+	Attachments []MemosAttachment
+}
+
+// MemosDailyData 每日Memos模板数据
+type MemosDailyData struct {
+	Date        time.Time
+	FrontMatter string
+	Records     []MemosRecordData
+}
+
+// MemosDayLink Memos汇总中指向每日摘要的链接
+type MemosDayLink struct {
+	Date time.Time
+	File string // 每日摘要文件名（不含扩展名）
+}
+
+// MemosPeriodData 每周、每月Memos汇总模板数据
+type MemosPeriodData struct {
+	Start       time.Time
+	End         time.Time
+	Year        int
+	Week        int
+	FrontMatter string
+	Days        []MemosDayLink // 周期内有记录的日期
+}
+
+// Renderer Markdown 模板渲染器，优先使用模板目录中的同名文件，否则使用内置模板
+type Renderer struct {
+	dir       string
+	templates map[string]*template.Template
+}
+
+// NewRenderer 创建模板渲染器
+// 模板目录由 TEMPLATE_DIR 指定，相对路径相对于输出目录（即 Obsidian 仓库）
+func NewRenderer(outputDir string) *Renderer {
+	dir := os.Getenv("TEMPLATE_DIR")
+	if dir != "" && !filepath.IsAbs(dir) {
+		dir = filepath.Join(outputDir, dir)
+	}
+	return &Renderer{
+		dir:       dir,
+		templates: make(map[string]*template.Template),
+	}
+}
+
+// Render 使用指定模板渲染数据
+func (r *Renderer) Render(name string, data interface{}) (string, error) {
+	tmpl, err := r.lookup(name)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("渲染模板 %s 失败: %v", name, err)
+	}
+	return buf.String(), nil
+}
+
+// lookup 加载并缓存模板，自定义模板解析失败时回退到内置模板
+func (r *Renderer) lookup(name string) (*template.Template, error) {
+	if tmpl, ok := r.templates[name]; ok {
+		return tmpl, nil
+	}
+
+	if r.dir != "" {
+		path := filepath.Join(r.dir, name)
+		if content, err := os.ReadFile(path); err == nil {
+			tmpl, err := template.New(name).Funcs(templateFuncs()).Parse(string(content))
+			if err == nil {
+				r.templates[name] = tmpl
+				return tmpl, nil
+			}
+			fmt.Printf("解析自定义模板 %s 失败，使用内置模板: %v\n", path, err)
+		}
+	}
+
+	content, err := builtinTemplates.ReadFile("templates/" + name)
+	if err != nil {
+		return nil, fmt.Errorf("内置模板 %s 不存在", name)
+	}
+	tmpl, err := template.New(name).Funcs(templateFuncs()).Parse(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("解析内置模板 %s 失败: %v", name, err)
+	}
+	r.templates[name] = tmpl
+	return tmpl, nil
+}
+
+// templateFuncs 模板中可用的辅助函数
+func templateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"deref":        deref,
+		"formatTime":   formatTimeValue,
+		"priorityMark": utils.GetPriorityMark,
+		"timeRange":    formatTaskTimeRange,
+		"taskLine":     formatTaskLine,
+		"indexLine":    formatIndexLine,
+		"unixTime":     func(ts int64) time.Time { return time.Unix(ts, 0).In(utils.Location()) },
+		"add":          func(a, b int) int { return a + b },
+		"join":         strings.Join,
+		"trim":         strings.TrimSpace,
+		"weekday":      weekdayName,
+		"number":       formatNumber,
+	}
+}
+
+// weekdayNames 星期的中文名称
+var weekdayNames = [...]string{"周日", "周一", "周二", "周三", "周四", "周五", "周六"}
+
+// weekdayName 返回日期对应的中文星期名称
+func weekdayName(t time.Time) string {
+	return weekdayNames[t.Weekday()]
+}
+
+// deref 读取指针的值，nil 时返回零值，便于在模板中直接输出
+func deref(value interface{}) interface{} {
+	switch v := value.(type) {
+	case *string:
+		if v == nil {
+			return ""
+		}
+		return *v
+	case *int:
+		if v == nil {
+			return 0
+		}
+		return *v
+	case *int64:
+		if v == nil {
+			return int64(0)
+		}
+		return *v
+	case *float64:
+		if v == nil {
+			return float64(0)
+		}
+		return *v
+	case *bool:
+		if v == nil {
+			return false
+		}
+		return *v
+	case *time.Time:
+		if v == nil {
+			return time.Time{}
+		}
+		return *v
+	default:
+		return value
+	}
+}
+
+// formatTimeValue 按指定格式输出时间，支持时间字符串与 time.Time
+func formatTimeValue(value interface{}, layout string) string {
+	switch v := deref(value).(type) {
+	case string:
+		return utils.FormatTime(v, layout)
+	case time.Time:
+		if v.IsZero() {
+			return ""
+		}
+		return v.Format(layout)
+	case int64:
+		return time.Unix(v, 0).In(utils.Location()).Format(layout)
+	default:
+		return ""
+	}
+}
+
+// displayFrontMatter 生成仅包含页面显示设置的 Front Matter
+func displayFrontMatter(cssclasses ...string) string {
+	frontMatter := utils.NewFrontMatter()
+	frontMatter.SetDisplay(cssclasses...)
+	return frontMatter.String()
+}
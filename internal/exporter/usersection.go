@@ -0,0 +1,29 @@
+package exporter
+
+import (
+	"os"
+	"strings"
+)
+
+// userSectionMarker 任务与笔记文件中受管理内容与用户内容的分界，之后的内容由用户维护，导出时原样保留
+const userSectionMarker = "<!-- dida365:user -->"
+
+// splitUserSection 将文件内容拆分为导出器生成的部分和用户维护的部分
+// 没有分界标记时（如旧版本导出的文件）全部视为生成的内容
+func splitUserSection(content string) (string, string) {
+	i := strings.Index(content, userSectionMarker)
+	if i < 0 {
+		return content, ""
+	}
+	user := strings.TrimPrefix(content[i+len(userSectionMarker):], "\n")
+	return content[:i], user
+}
+
+// withUserSection 在生成的内容之后追加分界标记，并保留文件中已有的用户内容
+func withUserSection(rendered, path string) string {
+	user := ""
+	if existing, err := os.ReadFile(path); err == nil {
+		_, user = splitUserSection(string(existing))
+	}
+	return strings.TrimRight(rendered, "\n") + "\n\n" + userSectionMarker + "\n" + user
+}
@@ -0,0 +1,521 @@
+// Package rrule 解析滴答清单任务的重复规则（RFC 5545 RRULE 及滴答清单扩展），并展开指定时间范围内的重复日期
+package rrule
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 重复频率
+const (
+	Daily   = "DAILY"
+	Weekly  = "WEEKLY"
+	Monthly = "MONTHLY"
+	Yearly  = "YEARLY"
+	Custom  = "CUSTOM" // 滴答清单的自定义日期（ERULE:NAME=CUSTOM;BYDATE=...）
+)
+
+// maxPeriods 展开时最多遍历的周期数，避免规则异常时死循环
+const maxPeriods = 10000
+
+// weekdayCodes RRULE 中的星期缩写
+var weekdayCodes = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// weekdayNames 星期的中文名称
+var weekdayNames = [...]string{"周日", "周一", "周二", "周三", "周四", "周五", "周六"}
+
+// Weekday BYDAY 中的星期，N 不为 0 时表示当月（或当年）的第 N 个该星期，负数从末尾计算
+type Weekday struct {
+	N   int
+	Day time.Weekday
+}
+
+// Rule 解析后的重复规则
+type Rule struct {
+	Freq        string
+	Interval    int
+	Count       int        // 重复次数，0 表示不限
+	Until       *time.Time // 结束日期（含）
+	ByDay       []Weekday
+	ByMonthDay  []int
+	ByMonth     []int
+	BySetPos    []int
+	Dates       []time.Time // 自定义日期
+	SkipWeekend bool        // TT_SKIP=WEEKEND，跳过周末
+	SkipHoliday bool        // TT_SKIP=HOLIDAY，跳过法定节假日（无节假日数据，不展开）
+	Lunar       bool        // 农历重复（无农历数据，不展开）
+	Times       int         // TT_TIMES，习惯每个周期内需完成的次数，不限定具体日期
+}
+
+// Parse 解析重复规则，如 "RRULE:FREQ=WEEKLY;INTERVAL=1;BYDAY=MO,WE"
+// 日期相关的值按 loc 解析
+func Parse(value string, loc *time.Location) (*Rule, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, fmt.Errorf("重复规则为空")
+	}
+
+	prefix := ""
+	if i := strings.Index(value, ":"); i >= 0 {
+		prefix = strings.ToUpper(value[:i])
+		value = value[i+1:]
+	}
+
+	rule := &Rule{Interval: 1}
+	if prefix == "LUNAR" {
+		rule.Lunar = true
+	}
+
+	for _, part := range strings.Split(value, ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		key, val := strings.ToUpper(strings.TrimSpace(kv[0])), strings.TrimSpace(kv[1])
+
+		switch key {
+		case "FREQ":
+			rule.Freq = strings.ToUpper(val)
+		case "NAME":
+			if strings.ToUpper(val) == Custom {
+				rule.Freq = Custom
+			}
+		case "INTERVAL":
+			if n, err := strconv.Atoi(val); err == nil && n > 0 {
+				rule.Interval = n
+			}
+		case "COUNT":
+			if n, err := strconv.Atoi(val); err == nil && n > 0 {
+				rule.Count = n
+			}
+		case "UNTIL":
+			until, err := parseDate(val, loc)
+			if err != nil {
+				return nil, fmt.Errorf("解析 UNTIL 失败: %v", err)
+			}
+			rule.Until = &until
+		case "BYDAY":
+			for _, code := range strings.Split(val, ",") {
+				day, err := parseWeekday(code)
+				if err != nil {
+					return nil, err
+				}
+				rule.ByDay = append(rule.ByDay, day)
+			}
+		case "BYMONTHDAY":
+			rule.ByMonthDay = parseInts(val)
+		case "BYMONTH":
+			rule.ByMonth = parseInts(val)
+		case "BYSETPOS":
+			rule.BySetPos = parseInts(val)
+		case "BYDATE":
+			for _, s := range strings.Split(val, ",") {
+				date, err := parseDate(s, loc)
+				if err != nil {
+					return nil, fmt.Errorf("解析 BYDATE 失败: %v", err)
+				}
+				rule.Dates = append(rule.Dates, date)
+			}
+		case "TT_SKIP":
+			for _, s := range strings.Split(strings.ToUpper(val), ",") {
+				switch s {
+				case "WEEKEND":
+					rule.SkipWeekend = true
+				case "HOLIDAY":
+					rule.SkipHoliday = true
+				}
+			}
+		case "TT_TIMES":
+			if n, err := strconv.Atoi(val); err == nil && n > 0 {
+				rule.Times = n
+			}
+		case "TT_LUNAR", "LUNAR":
+			rule.Lunar = true
+		}
+	}
+
+	switch rule.Freq {
+	case Daily, Weekly, Monthly, Yearly, Custom:
+	default:
+		return nil, fmt.Errorf("不支持的重复频率: %s", rule.Freq)
+	}
+	return rule, nil
+}
+
+// Expandable 规则能否展开出具体日期，农历与跳过节假日的规则缺少日历数据，无法展开
+func (r *Rule) Expandable() bool {
+	return !r.Lunar && !r.SkipHoliday
+}
+
+// Between 返回从 start 开始的重复中落在 [from, to] 内的日期
+// start 为第一次发生的时间，exdates 中与重复同一天的日期会被排除
+func (r *Rule) Between(start, from, to time.Time, exdates []time.Time) []time.Time {
+	if !r.Expandable() || to.Before(start) {
+		return nil
+	}
+
+	excluded := make(map[string]bool)
+	for _, d := range exdates {
+		excluded[d.In(start.Location()).Format("2006-01-02")] = true
+	}
+
+	var result []time.Time
+	count := 0
+	emit := func(t time.Time) bool {
+		if r.Until != nil && t.After(endOfDay(*r.Until)) {
+			return false
+		}
+		if r.Count > 0 && count >= r.Count {
+			return false
+		}
+		count++
+		if excluded[t.Format("2006-01-02")] {
+			return true
+		}
+		if r.SkipWeekend && (t.Weekday() == time.Saturday || t.Weekday() == time.Sunday) {
+			return true
+		}
+		if !t.Before(from) && !t.After(to) {
+			result = append(result, t)
+		}
+		return true
+	}
+
+	if r.Freq == Custom {
+		dates := append([]time.Time{}, r.Dates...)
+		sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+		for _, d := range dates {
+			t := time.Date(d.Year(), d.Month(), d.Day(), start.Hour(), start.Minute(), start.Second(), 0, start.Location())
+			if t.Before(start) {
+				continue
+			}
+			if t.After(to) || !emit(t) {
+				break
+			}
+		}
+		return result
+	}
+
+	for period := 0; period < maxPeriods; period++ {
+		candidates := r.candidates(start, period)
+		for _, t := range candidates {
+			if t.Before(start) {
+				continue
+			}
+			if t.After(to) || !emit(t) {
+				return result
+			}
+		}
+	}
+	return result
+}
+
+// candidates 返回第 period 个周期内符合规则的日期，按时间排序
+func (r *Rule) candidates(start time.Time, period int) []time.Time {
+	at := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, start.Hour(), start.Minute(), start.Second(), 0, start.Location())
+	}
+
+	var days []time.Time
+	switch r.Freq {
+	case Daily:
+		d := start.AddDate(0, 0, period*r.Interval)
+		if r.matchMonth(d) && r.matchMonthDay(d) && r.matchWeekday(d) {
+			days = append(days, d)
+		}
+	case Weekly:
+		offset := (int(start.Weekday()) + 6) % 7 // 距离周一的天数
+		monday := start.AddDate(0, 0, -offset+period*7*r.Interval)
+		for i := 0; i < 7; i++ {
+			d := monday.AddDate(0, 0, i)
+			if len(r.ByDay) == 0 && d.Weekday() != start.Weekday() {
+				continue
+			}
+			if r.matchWeekday(d) && r.matchMonth(d) {
+				days = append(days, d)
+			}
+		}
+	case Monthly:
+		first := at(start.Year(), start.Month()+time.Month(period*r.Interval), 1)
+		if r.matchMonth(first) {
+			days = r.monthDays(first, start)
+		}
+	case Yearly:
+		year := start.Year() + period*r.Interval
+		months := r.ByMonth
+		if len(months) == 0 {
+			months = []int{int(start.Month())}
+		}
+		for _, m := range months {
+			days = append(days, r.monthDays(at(year, time.Month(m), 1), start)...)
+		}
+	}
+
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	return r.applySetPos(days)
+}
+
+// monthDays 返回 first 所在月份中符合 BYMONTHDAY/BYDAY 的日期，都未指定时使用开始日期的日
+func (r *Rule) monthDays(first, start time.Time) []time.Time {
+	last := first.AddDate(0, 1, -1).Day()
+	var days []time.Time
+
+	switch {
+	case len(r.ByMonthDay) > 0:
+		for _, n := range r.ByMonthDay {
+			day := n
+			if n < 0 {
+				day = last + n + 1
+			}
+			if day >= 1 && day <= last {
+				d := first.AddDate(0, 0, day-1)
+				if r.matchWeekday(d) {
+					days = append(days, d)
+				}
+			}
+		}
+	case len(r.ByDay) > 0:
+		for _, wd := range r.ByDay {
+			var matches []time.Time
+			for day := 1; day <= last; day++ {
+				d := first.AddDate(0, 0, day-1)
+				if d.Weekday() == wd.Day {
+					matches = append(matches, d)
+				}
+			}
+			switch {
+			case wd.N > 0 && wd.N <= len(matches):
+				days = append(days, matches[wd.N-1])
+			case wd.N < 0 && -wd.N <= len(matches):
+				days = append(days, matches[len(matches)+wd.N])
+			case wd.N == 0:
+				days = append(days, matches...)
+			}
+		}
+	default:
+		if start.Day() <= last {
+			days = append(days, first.AddDate(0, 0, start.Day()-1))
+		}
+	}
+	return days
+}
+
+// applySetPos 按 BYSETPOS 从周期内的日期中选取
+func (r *Rule) applySetPos(days []time.Time) []time.Time {
+	if len(r.BySetPos) == 0 || len(days) == 0 {
+		return days
+	}
+	var selected []time.Time
+	for _, pos := range r.BySetPos {
+		switch {
+		case pos > 0 && pos <= len(days):
+			selected = append(selected, days[pos-1])
+		case pos < 0 && -pos <= len(days):
+			selected = append(selected, days[len(days)+pos])
+		}
+	}
+	sort.Slice(selected, func(i, j int) bool { return selected[i].Before(selected[j]) })
+	return selected
+}
+
+// matchWeekday 日期是否符合 BYDAY（仅比较星期，用于按天、按周重复）
+func (r *Rule) matchWeekday(d time.Time) bool {
+	if len(r.ByDay) == 0 || r.Freq == Monthly || r.Freq == Yearly {
+		return true
+	}
+	for _, wd := range r.ByDay {
+		if wd.Day == d.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+// matchMonthDay 日期是否符合 BYMONTHDAY
+func (r *Rule) matchMonthDay(d time.Time) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	last := time.Date(d.Year(), d.Month()+1, 0, 0, 0, 0, 0, d.Location()).Day()
+	for _, n := range r.ByMonthDay {
+		if n == d.Day() || (n < 0 && last+n+1 == d.Day()) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchMonth 日期是否符合 BYMONTH
+func (r *Rule) matchMonth(d time.Time) bool {
+	if len(r.ByMonth) == 0 || r.Freq == Yearly {
+		return true
+	}
+	for _, m := range r.ByMonth {
+		if time.Month(m) == d.Month() {
+			return true
+		}
+	}
+	return false
+}
+
+// String 返回规则的中文描述，如 "每2周的周一、周三，共10次"
+func (r *Rule) String() string {
+	var b strings.Builder
+
+	unit := map[string]string{Daily: "天", Weekly: "周", Monthly: "月", Yearly: "年"}[r.Freq]
+	switch {
+	case r.Freq == Custom:
+		dates := make([]string, 0, len(r.Dates))
+		for _, d := range r.Dates {
+			dates = append(dates, d.Format("2006-01-02"))
+		}
+		b.WriteString("自定义日期：" + strings.Join(dates, "、"))
+	case r.Interval > 1:
+		fmt.Fprintf(&b, "每%d%s", r.Interval, unit)
+	default:
+		b.WriteString("每" + unit)
+	}
+	if r.Lunar {
+		b.WriteString("（农历）")
+	}
+
+	if len(r.ByMonth) > 0 {
+		months := make([]string, 0, len(r.ByMonth))
+		for _, m := range r.ByMonth {
+			months = append(months, fmt.Sprintf("%d月", m))
+		}
+		b.WriteString(strings.Join(months, "、"))
+	}
+	if len(r.ByMonthDay) > 0 {
+		days := make([]string, 0, len(r.ByMonthDay))
+		for _, n := range r.ByMonthDay {
+			if n < 0 {
+				days = append(days, fmt.Sprintf("倒数第%d天", -n))
+			} else {
+				days = append(days, fmt.Sprintf("%d日", n))
+			}
+		}
+		b.WriteString(strings.Join(days, "、"))
+	}
+	if len(r.ByDay) > 0 {
+		setPos := r.BySetPos
+		if len(r.ByDay) > 1 {
+			setPos = nil
+		}
+		days := make([]string, 0, len(r.ByDay))
+		for _, wd := range r.ByDay {
+			days = append(days, describeWeekday(wd, setPos))
+		}
+		if r.Freq == Weekly || r.Freq == Daily {
+			b.WriteString("的")
+		}
+		b.WriteString(strings.Join(days, "、"))
+		if len(r.ByDay) > 1 && len(r.BySetPos) == 1 {
+			b.WriteString("中的" + describePosition(r.BySetPos[0]))
+		}
+	}
+
+	if r.Times > 0 {
+		fmt.Fprintf(&b, "%d次", r.Times)
+	}
+	if r.SkipWeekend {
+		b.WriteString("，跳过周末")
+	}
+	if r.SkipHoliday {
+		b.WriteString("，跳过节假日")
+	}
+	if r.Count > 0 {
+		fmt.Fprintf(&b, "，共%d次", r.Count)
+	}
+	if r.Until != nil {
+		fmt.Fprintf(&b, "，至%s", r.Until.Format("2006-01-02"))
+	}
+	return b.String()
+}
+
+// describeWeekday 星期的中文描述，如 "第2个周一"、"最后一个周五"
+func describeWeekday(wd Weekday, setPos []int) string {
+	n := wd.N
+	if n == 0 && len(setPos) == 1 {
+		n = setPos[0]
+	}
+	if n == 0 {
+		return weekdayNames[wd.Day]
+	}
+	return describePosition(n) + weekdayNames[wd.Day]
+}
+
+// describePosition 序号的中文描述，如 "第2个"、"最后一个"、"倒数第2个"
+func describePosition(n int) string {
+	switch {
+	case n == -1:
+		return "最后一个"
+	case n < 0:
+		return fmt.Sprintf("倒数第%d个", -n)
+	default:
+		return fmt.Sprintf("第%d个", n)
+	}
+}
+
+// parseWeekday 解析 BYDAY 中的一项，如 "MO"、"2TU"、"-1FR"
+func parseWeekday(code string) (Weekday, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) < 2 {
+		return Weekday{}, fmt.Errorf("无法解析 BYDAY: %s", code)
+	}
+	day, ok := weekdayCodes[code[len(code)-2:]]
+	if !ok {
+		return Weekday{}, fmt.Errorf("无法解析 BYDAY: %s", code)
+	}
+	wd := Weekday{Day: day}
+	if prefix := code[:len(code)-2]; prefix != "" {
+		n, err := strconv.Atoi(strings.TrimPrefix(prefix, "+"))
+		if err != nil {
+			return Weekday{}, fmt.Errorf("无法解析 BYDAY: %s", code)
+		}
+		wd.N = n
+	}
+	return wd, nil
+}
+
+// parseInts 解析逗号分隔的整数，忽略无法解析的项
+func parseInts(value string) []int {
+	var result []int
+	for _, s := range strings.Split(value, ",") {
+		if n, err := strconv.Atoi(strings.TrimSpace(s)); err == nil {
+			result = append(result, n)
+		}
+	}
+	return result
+}
+
+// parseDate 解析 RRULE 中的日期，如 "20240131"、"20240131T160000Z"
+func parseDate(value string, loc *time.Location) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if t, err := time.Parse("20060102T150405Z", value); err == nil {
+		t = t.In(loc)
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc), nil
+	}
+	for _, layout := range []string{"20060102T150405", "20060102", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("无法解析日期: %s", value)
+}
+
+// endOfDay 当天的最后一秒
+func endOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 23, 59, 59, 0, t.Location())
+}
@@ -0,0 +1,286 @@
+package rrule
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func mustLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("缺少时区数据 %s: %v", name, err)
+	}
+	return loc
+}
+
+func TestBetween(t *testing.T) {
+	loc := mustLocation(t, "Asia/Shanghai")
+	date := func(s string) time.Time {
+		d, err := time.ParseInLocation("2006-01-02", s, loc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d.Add(9 * time.Hour)
+	}
+
+	tests := []struct {
+		name    string
+		rule    string
+		start   string
+		from    string
+		to      string
+		exdates []string
+		want    []string
+	}{
+		{
+			name:  "daily",
+			rule:  "RRULE:FREQ=DAILY",
+			start: "2024-01-01", from: "2024-01-01", to: "2024-01-04",
+			want: []string{"2024-01-01", "2024-01-02", "2024-01-03", "2024-01-04"},
+		},
+		{
+			name:  "daily interval",
+			rule:  "RRULE:FREQ=DAILY;INTERVAL=3",
+			start: "2024-01-01", from: "2024-01-01", to: "2024-01-10",
+			want: []string{"2024-01-01", "2024-01-04", "2024-01-07", "2024-01-10"},
+		},
+		{
+			name:  "range after start keeps phase",
+			rule:  "RRULE:FREQ=DAILY;INTERVAL=2",
+			start: "2024-01-01", from: "2024-01-06", to: "2024-01-10",
+			want: []string{"2024-01-07", "2024-01-09"},
+		},
+		{
+			name:  "weekly same weekday",
+			rule:  "RRULE:FREQ=WEEKLY",
+			start: "2024-01-03", from: "2024-01-01", to: "2024-01-31",
+			want: []string{"2024-01-03", "2024-01-10", "2024-01-17", "2024-01-24", "2024-01-31"},
+		},
+		{
+			name:  "weekly interval byday",
+			rule:  "RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR",
+			start: "2024-01-01", from: "2024-01-01", to: "2024-01-31",
+			want: []string{"2024-01-01", "2024-01-05", "2024-01-15", "2024-01-19", "2024-01-29"},
+		},
+		{
+			name:  "weekly byday skips days before start",
+			rule:  "RRULE:FREQ=WEEKLY;BYDAY=MO,WE",
+			start: "2024-01-03", from: "2024-01-01", to: "2024-01-10",
+			want: []string{"2024-01-03", "2024-01-08", "2024-01-10"},
+		},
+		{
+			name:  "monthly bymonthday",
+			rule:  "RRULE:FREQ=MONTHLY;BYMONTHDAY=1,15",
+			start: "2024-01-01", from: "2024-01-01", to: "2024-02-29",
+			want: []string{"2024-01-01", "2024-01-15", "2024-02-01", "2024-02-15"},
+		},
+		{
+			name:  "monthly last day",
+			rule:  "RRULE:FREQ=MONTHLY;BYMONTHDAY=-1",
+			start: "2024-01-31", from: "2024-01-01", to: "2024-04-30",
+			want: []string{"2024-01-31", "2024-02-29", "2024-03-31", "2024-04-30"},
+		},
+		{
+			name:  "monthly on 31st skips short months",
+			rule:  "RRULE:FREQ=MONTHLY",
+			start: "2024-01-31", from: "2024-01-01", to: "2024-05-31",
+			want: []string{"2024-01-31", "2024-03-31", "2024-05-31"},
+		},
+		{
+			name:  "monthly nth weekday",
+			rule:  "RRULE:FREQ=MONTHLY;BYDAY=2TU",
+			start: "2024-01-09", from: "2024-01-01", to: "2024-03-31",
+			want: []string{"2024-01-09", "2024-02-13", "2024-03-12"},
+		},
+		{
+			name:  "monthly last friday",
+			rule:  "RRULE:FREQ=MONTHLY;BYDAY=-1FR",
+			start: "2024-01-26", from: "2024-01-01", to: "2024-03-31",
+			want: []string{"2024-01-26", "2024-02-23", "2024-03-29"},
+		},
+		{
+			name:  "monthly last weekday by setpos",
+			rule:  "RRULE:FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1",
+			start: "2024-01-31", from: "2024-01-01", to: "2024-03-31",
+			want: []string{"2024-01-31", "2024-02-29", "2024-03-29"},
+		},
+		{
+			name:  "yearly leap day",
+			rule:  "RRULE:FREQ=YEARLY",
+			start: "2024-02-29", from: "2024-01-01", to: "2032-12-31",
+			want: []string{"2024-02-29", "2028-02-29", "2032-02-29"},
+		},
+		{
+			name:  "yearly bymonth",
+			rule:  "RRULE:FREQ=YEARLY;BYMONTH=3,9;BYMONTHDAY=1",
+			start: "2024-03-01", from: "2024-01-01", to: "2025-06-30",
+			want: []string{"2024-03-01", "2024-09-01", "2025-03-01"},
+		},
+		{
+			name:  "count",
+			rule:  "RRULE:FREQ=DAILY;COUNT=3",
+			start: "2024-01-01", from: "2024-01-01", to: "2024-01-31",
+			want: []string{"2024-01-01", "2024-01-02", "2024-01-03"},
+		},
+		{
+			name:  "count from series start",
+			rule:  "RRULE:FREQ=WEEKLY;COUNT=5",
+			start: "2024-01-01", from: "2024-01-20", to: "2024-03-31",
+			want: []string{"2024-01-22", "2024-01-29"},
+		},
+		{
+			name:  "until inclusive",
+			rule:  "RRULE:FREQ=DAILY;UNTIL=20240103T000000Z",
+			start: "2024-01-01", from: "2024-01-01", to: "2024-01-31",
+			want: []string{"2024-01-01", "2024-01-02", "2024-01-03"},
+		},
+		{
+			name:  "exdate counts toward count",
+			rule:  "RRULE:FREQ=DAILY;COUNT=4",
+			start: "2024-01-01", from: "2024-01-01", to: "2024-01-31",
+			exdates: []string{"2024-01-02"},
+			want:    []string{"2024-01-01", "2024-01-03", "2024-01-04"},
+		},
+		{
+			name:  "skip weekend",
+			rule:  "RRULE:FREQ=DAILY;TT_SKIP=WEEKEND",
+			start: "2024-01-05", from: "2024-01-01", to: "2024-01-09",
+			want: []string{"2024-01-05", "2024-01-08", "2024-01-09"},
+		},
+		{
+			name:  "custom dates",
+			rule:  "ERULE:NAME=CUSTOM;BYDATE=20240110,20240105,20231231",
+			start: "2024-01-01", from: "2024-01-01", to: "2024-01-31",
+			want: []string{"2024-01-05", "2024-01-10"},
+		},
+		{
+			name:  "range before start",
+			rule:  "RRULE:FREQ=DAILY",
+			start: "2024-02-01", from: "2024-01-01", to: "2024-01-31",
+			want: nil,
+		},
+		{
+			name:  "lunar is not expanded",
+			rule:  "LUNAR:FREQ=YEARLY",
+			start: "2024-01-01", from: "2024-01-01", to: "2026-01-01",
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := Parse(tt.rule, loc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var exdates []time.Time
+			for _, s := range tt.exdates {
+				exdates = append(exdates, date(s))
+			}
+			to := date(tt.to).Add(15 * time.Hour)
+
+			var got []string
+			for _, d := range rule.Between(date(tt.start), date(tt.from).Add(-9*time.Hour), to, exdates) {
+				if d.Hour() != 9 {
+					t.Errorf("%s 的时刻变为 %s", d.Format("2006-01-02"), d.Format("15:04"))
+				}
+				got = append(got, d.Format("2006-01-02"))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Between = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBetweenAcrossDST(t *testing.T) {
+	loc := mustLocation(t, "America/New_York")
+	rule, err := Parse("RRULE:FREQ=DAILY", loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 2024-03-10 开始夏令时，2024-11-03 结束
+	for _, start := range []time.Time{
+		time.Date(2024, 3, 9, 8, 30, 0, 0, loc),
+		time.Date(2024, 11, 2, 8, 30, 0, 0, loc),
+	} {
+		got := rule.Between(start, start, start.AddDate(0, 0, 3), nil)
+		if len(got) != 4 {
+			t.Fatalf("从 %s 开始得到 %d 次重复", start, len(got))
+		}
+		for i, d := range got {
+			if d.Hour() != 8 || d.Minute() != 30 || d.Day() != start.Day()+i {
+				t.Errorf("第 %d 次重复为 %s", i+1, d)
+			}
+		}
+	}
+
+	weekly, err := Parse("RRULE:FREQ=WEEKLY;BYDAY=SA,SU", loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2024, 3, 9, 23, 0, 0, 0, loc)
+	got := weekly.Between(start, start, start.AddDate(0, 0, 7), nil)
+	want := []int{9, 10, 16}
+	if len(got) != len(want) {
+		t.Fatalf("Between = %v", got)
+	}
+	for i, d := range got {
+		if d.Day() != want[i] || d.Hour() != 23 {
+			t.Errorf("第 %d 次重复为 %s", i+1, d)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	loc := mustLocation(t, "Asia/Shanghai")
+
+	rule, err := Parse("RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,-1FR;COUNT=5;UNTIL=20241231;TT_SKIP=WEEKEND,HOLIDAY", loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rule.Freq != Weekly || rule.Interval != 2 || rule.Count != 5 || !rule.SkipWeekend || !rule.SkipHoliday {
+		t.Errorf("Parse = %+v", rule)
+	}
+	if want := []Weekday{{Day: time.Monday}, {N: -1, Day: time.Friday}}; !reflect.DeepEqual(rule.ByDay, want) {
+		t.Errorf("ByDay = %v, want %v", rule.ByDay, want)
+	}
+	if rule.Until == nil || rule.Until.Format("2006-01-02") != "2024-12-31" {
+		t.Errorf("Until = %v", rule.Until)
+	}
+	if rule.Expandable() {
+		t.Error("跳过节假日的规则不应展开")
+	}
+
+	for _, value := range []string{"", "RRULE:FREQ=HOURLY", "RRULE:FREQ=WEEKLY;BYDAY=XX", "RRULE:FREQ=DAILY;UNTIL=bad"} {
+		if _, err := Parse(value, loc); err == nil {
+			t.Errorf("Parse(%q) 应返回错误", value)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		rule string
+		want string
+	}{
+		{"RRULE:FREQ=DAILY", "每天"},
+		{"RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR;COUNT=5", "每2周的周一、周五，共5次"},
+		{"RRULE:FREQ=MONTHLY;BYMONTHDAY=-1", "每月倒数第1天"},
+		{"RRULE:FREQ=MONTHLY;BYDAY=2TU", "每月第2个周二"},
+		{"RRULE:FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1", "每月周一、周二、周三、周四、周五中的最后一个"},
+		{"RRULE:FREQ=DAILY;TT_SKIP=WEEKEND;UNTIL=20240131", "每天，跳过周末，至2024-01-31"},
+	}
+	for _, tt := range tests {
+		rule, err := Parse(tt.rule, time.UTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := rule.String(); got != tt.want {
+			t.Errorf("%s: String() = %q, want %q", tt.rule, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,303 @@
+package state
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"exporter-to-obsidian/internal/utils"
+)
+
+// Session 数据源的登录会话
+type Session struct {
+	Username  string    `json:"username,omitempty"` // 会话所属账号，账号变更后需重新登录
+	Token     string    `json:"token"`
+	InboxID   string    `json:"inboxId,omitempty"`
+	LoginTime time.Time `json:"loginTime"`
+}
+
+// Checkpoint 增量同步的检查点
+type Checkpoint struct {
+	Value    int64     `json:"value"`
+	FullSync time.Time `json:"fullSync"` // 上次全量同步的时间
+}
+
+// Entity 单个上游对象的导出记录
+type Entity struct {
+	Version  string `json:"version,omitempty"`  // 上游版本，如 etag 或修改时间
+	Path     string `json:"path"`               // 输出文件相对输出目录的路径
+	Hash     string `json:"hash,omitempty"`     // 导出器生成内容的哈希
+	Parent   string `json:"parent,omitempty"`   // 所属的上游对象，如分组所在的项目
+	Status   int    `json:"status,omitempty"`   // 导出时的上游状态，如任务是否已完成
+	Modified string `json:"modified,omitempty"` // 导出时的上游修改时间
+}
+
+// data 状态文件的内容
+type data struct {
+	Sessions    map[string]Session    `json:"sessions,omitempty"` // 旧版本保存在状态文件中的登录会话，读取后迁移到会话文件
+	Checkpoints map[string]Checkpoint `json:"checkpoints"`
+	Entities    map[string]Entity     `json:"entities"`
+}
+
+// Store 本地同步状态，保存在 <STATE_DIR>/state.json，登录会话单独保存在 SESSION_FILE 中
+// 同一状态目录在进程内共享一个实例，客户端与各导出器的修改不会互相覆盖
+type Store struct {
+	mu           sync.Mutex
+	path         string
+	data         data
+	dirty        bool
+	sessionPath  string
+	sessions     map[string]Session
+	sessionDirty bool
+	// legacySessionPath 旧版本输出目录中的会话文件，迁移到 sessionPath 后删除
+	legacySessionPath string
+}
+
+var (
+	storesMu sync.Mutex
+	stores   = make(map[string]*Store)
+)
+
+// Path 状态文件路径
+func Path() string {
+	return filepath.Join(utils.GetStateDir(), "state.json")
+}
+
+// SessionPath 登录会话文件路径，默认为用户配置目录下的 exporter-to-obsidian/session.json
+// 会话文件包含登录 Token，默认放在输出目录之外，避免随笔记库被同步工具上传；无法确定用户配置目录时使用状态目录
+func SessionPath() string {
+	path := legacySessionPath()
+	if dir, err := os.UserConfigDir(); err == nil {
+		path = filepath.Join(dir, "exporter-to-obsidian", "session.json")
+	}
+	return utils.GetEnvOrDefault("SESSION_FILE", path)
+}
+
+// legacySessionPath 旧版本默认的会话文件路径 <STATE_DIR>/session.json，位于输出目录中
+func legacySessionPath() string {
+	return filepath.Join(utils.GetStateDir(), "session.json")
+}
+
+// Open 获取当前状态目录的状态，首次调用时从文件读取
+// 文件不存在或损坏时返回空的状态，之后保存时会被覆盖
+func Open() *Store {
+	path := Path()
+
+	storesMu.Lock()
+	defer storesMu.Unlock()
+	if store, ok := stores[path]; ok {
+		return store
+	}
+
+	store := &Store{path: path, sessionPath: SessionPath()}
+	if content, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(content, &store.data); err != nil {
+			fmt.Printf("解析状态文件失败，将执行全量同步: %v\n", err)
+			store.data = data{}
+		}
+	} else if !os.IsNotExist(err) {
+		fmt.Printf("读取状态文件失败: %v\n", err)
+	}
+	sessionPath := store.sessionPath
+	// 会话文件尚不存在时迁移旧版本输出目录中的会话文件
+	if legacy := legacySessionPath(); legacy != sessionPath {
+		if _, err := os.Stat(sessionPath); os.IsNotExist(err) {
+			if _, err := os.Stat(legacy); err == nil {
+				sessionPath = legacy
+				store.legacySessionPath = legacy
+				store.sessionDirty = true
+			}
+		}
+	}
+	if content, err := os.ReadFile(sessionPath); err == nil {
+		if err := json.Unmarshal(content, &store.sessions); err != nil {
+			fmt.Printf("解析会话文件失败，将重新登录: %v\n", err)
+			store.sessions = nil
+		}
+	} else if !os.IsNotExist(err) {
+		fmt.Printf("读取会话文件失败: %v\n", err)
+	}
+	if store.sessions == nil {
+		store.sessions = make(map[string]Session)
+	}
+	// 将旧版本状态文件中的会话迁移到会话文件
+	if len(store.data.Sessions) > 0 {
+		for source, session := range store.data.Sessions {
+			if _, ok := store.sessions[source]; !ok {
+				store.sessions[source] = session
+			}
+		}
+		store.data.Sessions = nil
+		store.dirty, store.sessionDirty = true, true
+	}
+	if store.data.Checkpoints == nil {
+		store.data.Checkpoints = make(map[string]Checkpoint)
+	}
+	if store.data.Entities == nil {
+		store.data.Entities = make(map[string]Entity)
+	}
+
+	stores[path] = store
+	return store
+}
+
+// Save 保存状态，没有修改时不写入
+// 会话文件中包含登录 Token，只有当前用户可以读写（0600）
+func (s *Store) Save() error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.sessionDirty {
+		content, err := json.MarshalIndent(s.sessions, "", "  ")
+		if err != nil {
+			return fmt.Errorf("序列化登录会话失败: %v", err)
+		}
+		// 会话目录只有当前用户可以访问
+		if err := os.MkdirAll(filepath.Dir(s.sessionPath), 0700); err != nil {
+			return fmt.Errorf("创建会话目录失败: %v", err)
+		}
+		if _, err := utils.WriteFile(s.sessionPath, content, 0600); err != nil {
+			return fmt.Errorf("保存登录会话失败: %v", err)
+		}
+		// 内容未变化时不会重新写入，单独收紧已有文件的权限
+		if err := os.Chmod(s.sessionPath, 0600); err != nil {
+			return fmt.Errorf("设置会话文件权限失败: %v", err)
+		}
+		if s.legacySessionPath != "" {
+			if err := os.Remove(s.legacySessionPath); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("删除旧会话文件失败: %v", err)
+			}
+			s.legacySessionPath = ""
+		}
+		s.sessionDirty = false
+	}
+
+	if s.dirty {
+		content, err := json.MarshalIndent(s.data, "", "  ")
+		if err != nil {
+			return fmt.Errorf("序列化状态失败: %v", err)
+		}
+		if _, err := utils.WriteFile(s.path, content, 0644); err != nil {
+			return fmt.Errorf("保存状态失败: %v", err)
+		}
+		s.dirty = false
+	}
+	return nil
+}
+
+// Session 获取数据源的登录会话
+func (s *Store) Session(source string) (Session, bool) {
+	if s == nil {
+		return Session{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[source]
+	return session, ok
+}
+
+// SetSession 设置数据源的登录会话
+func (s *Store) SetSession(source string, session Session) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[source] = session
+	s.sessionDirty = true
+}
+
+// Checkpoint 获取数据源的同步检查点，不存在时返回零值
+func (s *Store) Checkpoint(source string) Checkpoint {
+	if s == nil {
+		return Checkpoint{}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.data.Checkpoints[source]
+}
+
+// SetCheckpoint 设置数据源的同步检查点
+func (s *Store) SetCheckpoint(source string, checkpoint Checkpoint) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Checkpoints[source] = checkpoint
+	s.dirty = true
+}
+
+// Entity 获取对象的导出记录
+func (s *Store) Entity(key string) (Entity, bool) {
+	if s == nil {
+		return Entity{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entity, ok := s.data.Entities[key]
+	return entity, ok
+}
+
+// SetEntity 设置对象的导出记录
+func (s *Store) SetEntity(key string, entity Entity) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if existing, ok := s.data.Entities[key]; ok && existing == entity {
+		return
+	}
+	s.data.Entities[key] = entity
+	s.dirty = true
+}
+
+// DeleteEntity 删除对象的导出记录
+func (s *Store) DeleteEntity(key string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.data.Entities[key]; ok {
+		delete(s.data.Entities, key)
+		s.dirty = true
+	}
+}
+
+// Entities 获取键以 prefix 开头的所有对象记录
+func (s *Store) Entities(prefix string) map[string]Entity {
+	entities := make(map[string]Entity)
+	if s == nil {
+		return entities
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, entity := range s.data.Entities {
+		if strings.HasPrefix(key, prefix) {
+			entities[key] = entity
+		}
+	}
+	return entities
+}
+
+// Key 由数据源、对象类型与ID组成对象记录的键，如 dida365/task/<id>
+func Key(parts ...string) string {
+	return strings.Join(parts, "/")
+}
+
+// Hash 计算内容的 SHA-256 哈希
+func Hash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
@@ -14,6 +14,7 @@ type Tag struct {
 	Color     *string `json:"color,omitempty"`
 	Etag      *string `json:"etag,omitempty"`
 	Type      *string `json:"type,omitempty"`
+	Parent    *string `json:"parent,omitempty"` // 父标签名称，用于嵌套标签
 }
 
 // Project 表示滴答清单中的一个项目（清单）
@@ -71,6 +72,7 @@ type Task struct {
 	Priority      *int       `json:"priority,omitempty"`
 	IsAllDay      *bool      `json:"isAllDay,omitempty"`
 	RepeatFlag    *string    `json:"repeatFlag,omitempty"`
+	RepeatFirstDate *string  `json:"repeatFirstDate,omitempty"` // 重复序列第一次的日期，完成一次后 startDate 会后移
 	Progress      *int       `json:"progress,omitempty"`
 	Assignee      *string    `json:"assignee,omitempty"`
 	SortOrder     *float64   `json:"sortOrder,omitempty"`
@@ -79,6 +81,7 @@ type Task struct {
 	Kind          *string    `json:"kind,omitempty"`
 	CreatedTime   *string    `json:"createdTime,omitempty"`
 	ModifiedTime  *string    `json:"modifiedTime,omitempty"`
+	Etag          *string    `json:"etag,omitempty"`
 	CompletedTime *string    `json:"completedTime,omitempty"`
 	Tags          []string   `json:"tags,omitempty"`
 	TimeZone      *string    `json:"timeZone,omitempty"`
@@ -90,6 +93,8 @@ type Task struct {
 	// 预处理后的时间字段
 	ProcessedStartDate *time.Time `json:"-"`
 	ProcessedDueDate   *time.Time `json:"-"`
+	// 重复任务展开的后续重复，不是滴答清单中实际的任务
+	Occurrence bool `json:"-"`
 }
 
 // TaskItem 表示任务中的子项
@@ -140,7 +145,10 @@ type MemosResource struct {
 
 // MemosRecord 表示Memos记录
 type MemosRecord struct {
+	ID           *int64          `json:"id,omitempty"`
+	Name         *string         `json:"name,omitempty"` // v1 接口的资源名称，如 memos/123
 	RowStatus    *string         `json:"rowStatus,omitempty"`
+	Pinned       *bool           `json:"pinned,omitempty"`
 	UpdatedTs    *int64          `json:"updatedTs,omitempty"`
 	CreatedTs    *int64          `json:"createdTs,omitempty"`
 	CreatedAt    *string         `json:"createdAt,omitempty"`
@@ -151,9 +159,11 @@ type MemosRecord struct {
 
 // HabitCheckin 表示习惯打卡记录
 type HabitCheckin struct {
-	CheckinStamp *int    `json:"checkinStamp,omitempty"`
-	Status       *int    `json:"status,omitempty"`
-	CheckinTime  *string `json:"checkinTime,omitempty"`
+	CheckinStamp *int     `json:"checkinStamp,omitempty"`
+	Status       *int     `json:"status,omitempty"`
+	CheckinTime  *string  `json:"checkinTime,omitempty"`
+	Value        *float64 `json:"value,omitempty"` // 数值型习惯当天记录的数值
+	Goal         *float64 `json:"goal,omitempty"`
 }
 
 // HabitCheckinsResponse 表示习惯打卡响应
@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// WriteFile 原子地写入文件，返回是否实际写入
+// 内容先写入同目录下的临时文件并同步到磁盘，再重命名覆盖目标文件，避免中途崩溃或磁盘写满时留下残缺的文件；
+// 内容与现有文件完全相同时不写入，保持文件的修改时间不变
+func WriteFile(path string, content []byte, perm os.FileMode) (bool, error) {
+	if existing, err := os.ReadFile(path); err == nil && bytes.Equal(existing, content) {
+		return false, nil
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return false, fmt.Errorf("创建目录失败: %v", err)
+	}
+
+	// 临时文件以 . 开头，Obsidian 不会将其当作笔记
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return false, fmt.Errorf("创建临时文件失败: %v", err)
+	}
+	tmpPath := tmp.Name()
+	committed := false
+	defer func() {
+		if !committed {
+			tmp.Close()
+			os.Remove(tmpPath)
+		}
+	}()
+
+	if _, err := tmp.Write(content); err != nil {
+		return false, fmt.Errorf("写入临时文件失败: %v", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return false, fmt.Errorf("同步临时文件失败: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return false, fmt.Errorf("关闭临时文件失败: %v", err)
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		return false, fmt.Errorf("设置文件权限失败: %v", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return false, fmt.Errorf("替换文件失败: %v", err)
+	}
+	committed = true
+
+	// 同步目录，确保重命名在断电后仍然有效；部分文件系统不支持，忽略错误
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return true, nil
+}
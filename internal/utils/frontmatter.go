@@ -0,0 +1,437 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// FrontMatter 有序的 YAML Front Matter，支持标量与字符串列表
+type FrontMatter struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+// NewFrontMatter 创建空的 Front Matter
+func NewFrontMatter() *FrontMatter {
+	return &FrontMatter{values: make(map[string]interface{})}
+}
+
+// Set 设置字段，新字段追加到末尾，已有字段保持原有位置；值为 nil 时删除该字段
+// 支持 string、int、int64、float64、bool 与 []string
+func (f *FrontMatter) Set(key string, value interface{}) {
+	if value == nil {
+		f.Delete(key)
+		return
+	}
+	if _, exists := f.values[key]; !exists {
+		f.keys = append(f.keys, key)
+	}
+	f.values[key] = value
+}
+
+// Get 获取字段值
+func (f *FrontMatter) Get(key string) (interface{}, bool) {
+	value, ok := f.values[key]
+	return value, ok
+}
+
+// GetString 获取字段的字符串形式，不存在时返回空字符串
+func (f *FrontMatter) GetString(key string) string {
+	value, ok := f.values[key]
+	if !ok || value == nil {
+		return ""
+	}
+	switch v := value.(type) {
+	case string:
+		return v
+	case []string:
+		return strings.Join(v, ", ")
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// GetStrings 获取列表字段，单个标量视为只有一个元素的列表
+func (f *FrontMatter) GetStrings(key string) []string {
+	value, ok := f.values[key]
+	if !ok || value == nil {
+		return nil
+	}
+	if list, ok := value.([]string); ok {
+		return list
+	}
+	return []string{f.GetString(key)}
+}
+
+// Delete 删除字段
+func (f *FrontMatter) Delete(key string) {
+	if _, exists := f.values[key]; !exists {
+		return
+	}
+	delete(f.values, key)
+	for i, k := range f.keys {
+		if k == key {
+			f.keys = append(f.keys[:i], f.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// Keys 按顺序返回所有字段名
+func (f *FrontMatter) Keys() []string {
+	return append([]string(nil), f.keys...)
+}
+
+// SetDisplay 设置 Obsidian 页面显示相关字段（阅读模式与 cssclasses）
+func (f *FrontMatter) SetDisplay(cssclasses ...string) {
+	f.Set("obsidianUIMode", "preview")
+	f.Set("cssclasses", cssclasses)
+}
+
+// String 序列化为以 --- 包围的 Front Matter 文本
+func (f *FrontMatter) String() string {
+	var b strings.Builder
+	b.WriteString("---\n")
+	for _, key := range f.keys {
+		writeYAMLField(&b, key, f.values[key])
+	}
+	b.WriteString("---\n")
+	return b.String()
+}
+
+// writeYAMLField 写入单个字段
+func writeYAMLField(b *strings.Builder, key string, value interface{}) {
+	switch v := value.(type) {
+	case []string:
+		if len(v) == 0 {
+			fmt.Fprintf(b, "%s: []\n", key)
+			return
+		}
+		fmt.Fprintf(b, "%s:\n", key)
+		for _, item := range v {
+			fmt.Fprintf(b, "  - %s\n", quoteYAML(item))
+		}
+	case string:
+		fmt.Fprintf(b, "%s: %s\n", key, quoteYAML(v))
+	case bool, int, int64, float64:
+		fmt.Fprintf(b, "%s: %v\n", key, v)
+	default:
+		fmt.Fprintf(b, "%s: %s\n", key, quoteYAML(fmt.Sprintf("%v", v)))
+	}
+}
+
+// quoteYAML 在必要时为字符串加双引号并转义，保证解析结果仍为原字符串
+func quoteYAML(value string) string {
+	if isPlainYAML(value) {
+		return value
+	}
+
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range value {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\x%02x`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// isPlainYAML 判断字符串能否不加引号直接输出
+func isPlainYAML(value string) bool {
+	if value == "" || strings.TrimSpace(value) != value {
+		return false
+	}
+	if strings.ContainsAny(value[:1], "-?:,[]{}#&*!|>'\"%@`") {
+		return false
+	}
+	if strings.Contains(value, ": ") || strings.Contains(value, " #") || strings.HasSuffix(value, ":") {
+		return false
+	}
+	for _, r := range value {
+		if unicode.IsControl(r) {
+			return false
+		}
+	}
+	// 会被解析为其他类型的字符串
+	switch strings.ToLower(value) {
+	case "true", "false", "yes", "no", "on", "off", "null", "~", ".inf", "-.inf", ".nan":
+		return false
+	}
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return false
+	}
+	if _, err := strconv.ParseInt(value, 0, 64); err == nil {
+		return false
+	}
+	return true
+}
+
+// ParseFrontMatter 解析 Markdown 开头的 Front Matter，返回字段与其后的正文
+// 文件没有 Front Matter 时返回空的 FrontMatter 与完整内容
+func ParseFrontMatter(content string) (*FrontMatter, string, error) {
+	f := NewFrontMatter()
+
+	normalized := strings.ReplaceAll(content, "\r\n", "\n")
+	if !strings.HasPrefix(normalized, "---\n") {
+		return f, content, nil
+	}
+
+	rest := normalized[4:]
+	var block, body string
+	if strings.HasPrefix(rest, "---\n") || rest == "---" {
+		block, body = "", strings.TrimPrefix(strings.TrimPrefix(rest, "---"), "\n")
+	} else {
+		end := strings.Index(rest, "\n---\n")
+		if end < 0 {
+			if !strings.HasSuffix(rest, "\n---") {
+				return f, content, fmt.Errorf("Front Matter 缺少结束标记")
+			}
+			end = len(rest) - 4
+			block, body = rest[:end], ""
+		} else {
+			block, body = rest[:end], rest[end+5:]
+		}
+	}
+
+	lines := strings.Split(block, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			return f, body, fmt.Errorf("第 %d 行缩进无法识别: %s", i+1, line)
+		}
+
+		colon := strings.Index(line, ":")
+		if colon <= 0 {
+			return f, body, fmt.Errorf("第 %d 行缺少字段名: %s", i+1, line)
+		}
+		key := strings.TrimSpace(line[:colon])
+		raw := strings.TrimSpace(line[colon+1:])
+
+		switch {
+		case raw == "":
+			// 值为空时，后续缩进的 "- " 行构成列表
+			var items []string
+			for i+1 < len(lines) {
+				next := strings.TrimSpace(lines[i+1])
+				if !strings.HasPrefix(next, "- ") && next != "-" {
+					break
+				}
+				item, err := parseYAMLScalar(strings.TrimSpace(strings.TrimPrefix(next, "-")))
+				if err != nil {
+					return f, body, fmt.Errorf("第 %d 行: %v", i+2, err)
+				}
+				items = append(items, scalarString(item))
+				i++
+			}
+			if items != nil {
+				f.Set(key, items)
+			} else {
+				f.Set(key, "")
+			}
+		case raw == "|" || raw == "|-" || raw == ">" || raw == ">-":
+			var blockLines []string
+			for i+1 < len(lines) && (lines[i+1] == "" || strings.HasPrefix(lines[i+1], " ")) {
+				blockLines = append(blockLines, strings.TrimPrefix(lines[i+1], "  "))
+				i++
+			}
+			sep := "\n"
+			if raw[0] == '>' {
+				sep = " "
+			}
+			value := strings.Join(blockLines, sep)
+			if !strings.HasSuffix(raw, "-") {
+				value += "\n"
+			}
+			f.Set(key, value)
+		case strings.HasPrefix(raw, "["):
+			items, err := parseYAMLFlowList(raw)
+			if err != nil {
+				return f, body, fmt.Errorf("第 %d 行: %v", i+1, err)
+			}
+			f.Set(key, items)
+		default:
+			value, err := parseYAMLScalar(raw)
+			if err != nil {
+				return f, body, fmt.Errorf("第 %d 行: %v", i+1, err)
+			}
+			if value == nil {
+				value = ""
+			}
+			f.Set(key, value)
+		}
+	}
+
+	return f, body, nil
+}
+
+// parseYAMLFlowList 解析 [a, "b", c] 形式的列表
+func parseYAMLFlowList(raw string) ([]string, error) {
+	if !strings.HasSuffix(raw, "]") {
+		return nil, fmt.Errorf("列表缺少结束括号: %s", raw)
+	}
+	inner := strings.TrimSpace(raw[1 : len(raw)-1])
+	items := []string{}
+	for inner != "" {
+		var token string
+		if inner[0] == '"' || inner[0] == '\'' {
+			end := closingQuote(inner)
+			if end < 0 {
+				return nil, fmt.Errorf("引号未闭合: %s", raw)
+			}
+			token, inner = inner[:end+1], strings.TrimSpace(inner[end+1:])
+		} else {
+			comma := strings.Index(inner, ",")
+			if comma < 0 {
+				comma = len(inner)
+			}
+			token, inner = strings.TrimSpace(inner[:comma]), inner[comma:]
+		}
+		value, err := parseYAMLScalar(token)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, scalarString(value))
+		inner = strings.TrimSpace(strings.TrimPrefix(inner, ","))
+	}
+	return items, nil
+}
+
+// scalarString 将解析出的标量转换为字符串，null 视为空字符串
+func scalarString(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// closingQuote 返回与开头引号匹配的结束引号位置
+func closingQuote(s string) int {
+	quote := s[0]
+	for i := 1; i < len(s); i++ {
+		switch {
+		case quote == '"' && s[i] == '\\':
+			i++
+		case s[i] == quote:
+			if quote == '\'' && i+1 < len(s) && s[i+1] == '\'' {
+				i++
+				continue
+			}
+			return i
+		}
+	}
+	return -1
+}
+
+// parseYAMLScalar 解析单个标量，返回 string、int、float64、bool 或 nil
+func parseYAMLScalar(raw string) (interface{}, error) {
+	if raw == "" {
+		return "", nil
+	}
+
+	switch raw[0] {
+	case '"':
+		end := closingQuote(raw)
+		if end != len(raw)-1 {
+			if end < 0 || !strings.HasPrefix(strings.TrimSpace(raw[end+1:]), "#") {
+				return nil, fmt.Errorf("双引号字符串格式错误: %s", raw)
+			}
+		}
+		return unescapeYAML(raw[1:end])
+	case '\'':
+		end := closingQuote(raw)
+		if end != len(raw)-1 {
+			if end < 0 || !strings.HasPrefix(strings.TrimSpace(raw[end+1:]), "#") {
+				return nil, fmt.Errorf("单引号字符串格式错误: %s", raw)
+			}
+		}
+		return strings.ReplaceAll(raw[1:end], "''", "'"), nil
+	}
+
+	// 去除行尾注释
+	if idx := strings.Index(raw, " #"); idx >= 0 {
+		raw = strings.TrimSpace(raw[:idx])
+	}
+
+	switch strings.ToLower(raw) {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null", "~":
+		return nil, nil
+	}
+	if i, err := strconv.Atoi(raw); err == nil {
+		return i, nil
+	}
+	if f, err := strconv.ParseFloat(raw, 64); err == nil {
+		return f, nil
+	}
+	return raw, nil
+}
+
+// unescapeYAML 还原双引号字符串中的转义字符
+func unescapeYAML(s string) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		if i >= len(s) {
+			return "", fmt.Errorf("转义字符不完整: %s", s)
+		}
+		switch s[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case '0':
+			b.WriteByte(0)
+		case '"', '\\', '/', ' ':
+			b.WriteByte(s[i])
+		case 'x', 'u', 'U':
+			size := map[byte]int{'x': 2, 'u': 4, 'U': 8}[s[i]]
+			if i+size >= len(s) {
+				return "", fmt.Errorf("转义字符不完整: %s", s)
+			}
+			code, err := strconv.ParseUint(s[i+1:i+1+size], 16, 32)
+			if err != nil {
+				return "", fmt.Errorf("转义字符格式错误: %s", s)
+			}
+			b.WriteRune(rune(code))
+			i += size
+		default:
+			return "", fmt.Errorf("不支持的转义字符 \\%c", s[i])
+		}
+	}
+	return b.String(), nil
+}
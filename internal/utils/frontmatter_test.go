@@ -0,0 +1,117 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFrontMatterRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+	}{
+		{"plain", "买菜"},
+		{"colon", "会议: 周报"},
+		{"trailing colon", "备注:"},
+		{"url", "https://dida365.com/webapp/#p/1/tasks/2"},
+		{"hash", "#标签"},
+		{"inline comment", "完成 #1"},
+		{"double quote", `他说"好"`},
+		{"single quote", "it's"},
+		{"leading quote", `"引用"`},
+		{"backslash", `C:\path\to`},
+		{"leading dash", "- 列表项"},
+		{"dash only", "-"},
+		{"leading question mark", "?问题"},
+		{"flow chars", "[x] {y}"},
+		{"newline", "第一行\n第二行"},
+		{"crlf and tab", "a\r\nb\tc"},
+		{"surrounding spaces", "  空格  "},
+		{"empty", ""},
+		{"bool-like", "true"},
+		{"yes-like", "yes"},
+		{"null-like", "null"},
+		{"int-like", "123"},
+		{"float-like", "1.5"},
+		{"hex-like", "0x1F"},
+		{"time", "2024-01-02 03:04:05"},
+		{"control char", "a\x01b"},
+		{"int", 3},
+		{"bool", true},
+		{"list", []string{"工作", "a: b", "#tag", "- item", `"q"`, "多\n行", ""}},
+		{"empty list", []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewFrontMatter()
+			f.Set("value", tt.value)
+			f.Set("after", "end")
+
+			parsed, body, err := ParseFrontMatter(f.String() + "正文\n")
+			if err != nil {
+				t.Fatalf("解析失败: %v\n%s", err, f.String())
+			}
+			if body != "正文\n" {
+				t.Errorf("正文 = %q", body)
+			}
+			got, _ := parsed.Get("value")
+			if !reflect.DeepEqual(got, tt.value) {
+				t.Errorf("value = %#v, want %#v\n%s", got, tt.value, f.String())
+			}
+			if parsed.GetString("after") != "end" {
+				t.Errorf("after = %q\n%s", parsed.GetString("after"), f.String())
+			}
+			if !reflect.DeepEqual(parsed.Keys(), []string{"value", "after"}) {
+				t.Errorf("keys = %v", parsed.Keys())
+			}
+		})
+	}
+}
+
+func TestParseFrontMatter(t *testing.T) {
+	content := "---\n" +
+		"title: 'it''s'\n" +
+		"tags: [a, \"b, c\", 'd']\n" +
+		"items:\n" +
+		"  - x\n" +
+		"  - \"y: z\"\n" +
+		"note: |\n" +
+		"  line1\n" +
+		"  line2\n" +
+		"count: 5 # 注释\n" +
+		"empty:\n" +
+		"---\n" +
+		"body"
+
+	f, body, err := ParseFrontMatter(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != "body" {
+		t.Errorf("body = %q", body)
+	}
+	want := map[string]interface{}{
+		"title": "it's",
+		"tags":  []string{"a", "b, c", "d"},
+		"items": []string{"x", "y: z"},
+		"note":  "line1\nline2\n",
+		"count": 5,
+		"empty": "",
+	}
+	for key, value := range want {
+		if got, _ := f.Get(key); !reflect.DeepEqual(got, value) {
+			t.Errorf("%s = %#v, want %#v", key, got, value)
+		}
+	}
+}
+
+func TestParseFrontMatterWithoutBlock(t *testing.T) {
+	f, body, err := ParseFrontMatter("# 标题\n")
+	if err != nil || body != "# 标题\n" || len(f.Keys()) != 0 {
+		t.Errorf("got %v %q %v", f.Keys(), body, err)
+	}
+	if _, _, err := ParseFrontMatter("---\ntitle: x\n"); err == nil {
+		t.Error("缺少结束标记时应返回错误")
+	}
+}
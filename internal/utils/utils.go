@@ -3,9 +3,17 @@ package utils
 import (
 	"fmt"
 	"os"
-	"regexp"
+	"path/filepath"
 	"strings"
+	"sync"
 	"time"
+	_ "time/tzdata" // 运行环境缺少时区数据库时（如 scratch 镜像）使用内置数据
+)
+
+var (
+	locationMu   sync.Mutex
+	locationName string
+	location     *time.Location
 )
 
 // GetEnvOrDefault 获取环境变量，如果不存在则返回默认值
@@ -16,6 +24,70 @@ func GetEnvOrDefault(key, defaultValue string) string {
 	return defaultValue
 }
 
+// GetSecret 获取账号、密码、令牌等敏感配置
+// 设置了 <key>_FILE 时从该文件读取（如 Docker secrets 挂载的 /run/secrets/...），去掉末尾的换行；否则读取环境变量 key
+func GetSecret(key string) (string, error) {
+	path := os.Getenv(key + "_FILE")
+	if path == "" {
+		return os.Getenv(key), nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("读取 %s_FILE 失败: %v", key, err)
+	}
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
+
+// GetStateDir 获取同步状态目录，默认为输出目录下的 .exporter
+func GetStateDir() string {
+	if dir := os.Getenv("STATE_DIR"); dir != "" {
+		return dir
+	}
+	return filepath.Join(GetEnvOrDefault("OUTPUT_DIR", "."), ".exporter")
+}
+
+// Location 获取 TIME_ZONE 指定的时区（默认 Asia/Shanghai），所有日期计算与文件命名都使用该时区
+func Location() *time.Location {
+	name := GetEnvOrDefault("TIME_ZONE", "Asia/Shanghai")
+
+	locationMu.Lock()
+	defer locationMu.Unlock()
+	if location != nil && locationName == name {
+		return location
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		fmt.Printf("加载时区 %s 失败，使用东八区: %v\n", name, err)
+		loc = time.FixedZone("CST", 8*3600)
+	}
+	locationName, location = name, loc
+	return location
+}
+
+// LoadLocation 加载任务自带的时区，为空或无法识别时返回 TIME_ZONE 指定的时区
+func LoadLocation(name string) *time.Location {
+	if name == "" {
+		return Location()
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return Location()
+	}
+	return loc
+}
+
+// Now 获取当前时间（TIME_ZONE 时区）
+func Now() time.Time {
+	return time.Now().In(Location())
+}
+
+// StartOfDay 获取指定日期在 TIME_ZONE 时区的零点
+func StartOfDay(t time.Time) time.Time {
+	t = t.In(Location())
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, Location())
+}
+
 // GetPriorityMark 获取优先级标记
 func GetPriorityMark(priority *int) string {
 	if priority == nil {
@@ -47,14 +119,14 @@ func FormatTime(timeStr, format string) string {
 	return ""
 }
 
-// ParseDateTime 解析时间字符串为time.Time
+// ParseDateTime 解析时间字符串为time.Time，结果转换到 TIME_ZONE 时区
+// 不带时区的时间按 TIME_ZONE 时区解析
 func ParseDateTime(timeStr string) *time.Time {
 	if timeStr == "" {
 		return nil
 	}
 
-	// 定义东八区时区（北京时间）
-	east8Zone := time.FixedZone("CST", 8*3600) // 东八区，UTC+8
+	loc := Location()
 
 	// 支持的时间格式（含时区处理）
 	formats := []string{
@@ -63,14 +135,14 @@ func ParseDateTime(timeStr string) *time.Time {
 		"2006-01-02T15:04:05-07:00",    // 带冒号时区
 		"2006-01-02T15:04:05.000Z",     // UTC毫秒
 		"2006-01-02T15:04:05Z",         // UTC
-		"2006-01-02 15:04:05",          // 无时区（默认东八区）
+		"2006-01-02 15:04:05",          // 无时区（按 TIME_ZONE 解析）
 		"2006-01-02",                   // 日期
 	}
 
 	for _, format := range formats {
-		// 优先尝试带时区解析
-		if t, err := time.Parse(format, timeStr); err == nil {
-			t = t.In(east8Zone)
+		// 带时区的格式按字符串中的时区解析
+		if t, err := time.ParseInLocation(format, timeStr, loc); err == nil {
+			t = t.In(loc)
 			return &t
 		}
 	}
@@ -78,65 +150,40 @@ func ParseDateTime(timeStr string) *time.Time {
 	return nil
 }
 
-// ExtractFrontMatterField 从Front Matter中提取字段值
-func ExtractFrontMatterField(content, field string) string {
-	pattern := fmt.Sprintf(`%s:\s*(.*?)(?:\n|$)`, regexp.QuoteMeta(field))
-	re := regexp.MustCompile(pattern)
-	matches := re.FindStringSubmatch(content)
-	if len(matches) > 1 {
-		return strings.TrimSpace(matches[1])
-	}
-	return ""
-}
-
-// ConvertToBeijingTime 将ISO时间字符串转换为北京时间
-func ConvertToBeijingTime(isoTime string) string {
-	if isoTime == "" {
-		return ""
-	}
-
+// ConvertToLocalTime 将ISO时间字符串转换为 TIME_ZONE 时区的时间
+func ConvertToLocalTime(isoTime string) string {
 	t := ParseDateTime(isoTime)
 	if t == nil {
 		return ""
 	}
-
-	// 转换为北京时间 (UTC+8)
-	beijingLocation, _ := time.LoadLocation("Asia/Shanghai")
-	beijingTime := t.In(beijingLocation)
-	return beijingTime.Format("2006-01-02 15:04:05")
+	return t.Format("2006-01-02 15:04:05")
 }
 
-// GetTodayStamp 获取今天的时间戳
-func GetTodayStamp() int {
-	now := time.Now()
-	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
-	return int(today.Unix())
+// DateStamp 将日期转换为滴答清单习惯打卡使用的日期戳，如 20240105
+func DateStamp(t time.Time) int {
+	t = t.In(Location())
+	return t.Year()*10000 + int(t.Month())*100 + t.Day()
 }
 
-func getCssClasses(cssclasses []string) string { 
-	content := "cssclasses: \n"
-	for _, class := range cssclasses {
-		content += fmt.Sprintf("  - %s\n", class)
-	}
-	return content
+// ParseDateStamp 将打卡日期戳转换为 TIME_ZONE 时区中当天的零点
+func ParseDateStamp(stamp int) time.Time {
+	return time.Date(stamp/10000, time.Month(stamp/100%100), stamp%100, 0, 0, 0, 0, Location())
 }
 
-// GetFrontMatter 获取前言信息
-func GetFrontMatter(cssclasses []string, mate string) string {
-	frontMatter := map[string]interface{} {
-		"obsidianUIMode": "preview",
-	}
+// StartOfWeek 返回日期所在周（周一开始）第一天的零点
+func StartOfWeek(t time.Time) time.Time {
+	day := StartOfDay(t)
+	return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+}
 
-	content := "---\n"
-	if mate != "" {
-		content += mate
-	}
-	for key, value := range frontMatter {
-		if value != nil {
-			content += fmt.Sprintf("%s: %v\n", key, value)
-		}
+// ParseTaskDate 解析任务的开始或截止时间
+// 全天任务的日期以任务自身的时区为准，返回 TIME_ZONE 时区中同一天的零点；其他任务按时刻转换到 TIME_ZONE 时区
+func ParseTaskDate(timeStr, timeZone string, allDay bool) *time.Time {
+	t := ParseDateTime(timeStr)
+	if t == nil || !allDay {
+		return t
 	}
-	content += getCssClasses(cssclasses)
-	content += "---\n\n"
-	return content
+	local := t.In(LoadLocation(timeZone))
+	date := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, Location())
+	return &date
 }